@@ -0,0 +1,76 @@
+package helvargo
+
+import (
+	"github.com/nuqz/helvar-go/message"
+)
+
+// pool of schedulers, one per transceiver. Messages of the same group or
+// device always go to the same transceiver, so they reach the router over
+// the same connection in the order they were queued, while messages of
+// different targets are sent concurrently. Messages without a target go to
+// the least loaded transceiver.
+//
+// The order is kept per target only: a group and a device within it are
+// different targets, so a command to the group and a later command to the
+// device may reach the router in either order. Callers, which depend on
+// such an order, have to wait for the first command to be sent.
+type pool []*scheduler
+
+// pick returns a scheduler of a transceiver, which sends a given message.
+func (p pool) pick(msg *message.Message) *scheduler {
+	key := targetOf(msg)
+	if key == (target{}) {
+		out := p[0]
+		for _, s := range p[1:] {
+			if s.load.Load() < out.load.Load() {
+				out = s
+			}
+		}
+
+		return out
+	}
+
+	return p[key.shard(len(p))]
+}
+
+// FNV-1a parameters, see hash/fnv.
+const (
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
+
+// shard returns the index of a transceiver a target is bound to out of n.
+// It hashes the target with FNV-1a in place, since it runs for every
+// message.
+func (t target) shard(n int) int {
+	h := uint32(fnvOffset32)
+	h = (h ^ uint32(t.group>>8)) * fnvPrime32
+	h = (h ^ uint32(t.group&0xff)) * fnvPrime32
+	for i := 0; i < len(t.address); i++ {
+		h = (h ^ uint32(t.address[i])) * fnvPrime32
+	}
+
+	return int(h % uint32(n))
+}
+
+func (p pool) depth() (out int) {
+	for _, s := range p {
+		out += s.depth()
+	}
+
+	return out
+}
+
+func (p pool) capacity() (out int) {
+	for _, s := range p {
+		out += s.capacity()
+	}
+
+	return out
+}
+
+func (p pool) close() {
+	for _, s := range p {
+		s.close()
+	}
+}
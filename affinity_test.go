@@ -0,0 +1,103 @@
+package helvargo
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolPick(t *testing.T) {
+	p := pool{}
+	for i := 0; i < 4; i++ {
+		p = append(p, newScheduler(1, nil))
+	}
+
+	shards := map[*scheduler]bool{}
+	for gid := uint16(1); gid <= 64; gid++ {
+		s := p.pick(message.NewDirectLevelGroup(gid, 0))
+		assert.Same(t, s, p.pick(message.NewQueryGroupDescription(gid)))
+		shards[s] = true
+	}
+	assert.Len(t, shards, len(p))
+
+	assert.Same(t, p.pick(message.NewDirectLevelDevice("1.2.3.4", 0)),
+		p.pick(message.NewQueryDeviceState("1.2.3.4")))
+
+	p[0].load.Store(2)
+	p[1].load.Store(1)
+	p[2].load.Store(1)
+	p[3].load.Store(3)
+	assert.Same(t, p[1], p.pick(message.NewQueryGroups()))
+}
+
+func TestTargetShard(t *testing.T) {
+	for tcDescription, tc := range map[string]target{
+		"group":            {group: 1000},
+		"device":           {address: "1.2.3.4"},
+		"group and device": {group: 1, address: "1.2.3.4"},
+	} {
+		h := fnv.New32a()
+		_, _ = h.Write(binary.BigEndian.AppendUint16(nil, tc.group))
+		_, _ = h.Write([]byte(tc.address))
+		assert.Equal(t, int(h.Sum32()%7), tc.shard(7), tcDescription)
+	}
+
+	key := target{address: "1.2.3.4"}
+	assert.Zero(t, testing.AllocsPerRun(100, func() { key.shard(4) }))
+}
+
+func TestPoolOrdering(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		conns = map[uint16]map[int]bool{}
+		count int
+	)
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		gid := req.GetGroupID()
+		if conns[gid] == nil {
+			conns[gid] = map[int]bool{}
+		}
+		conns[gid][nConn] = true
+		count++
+		return ""
+	}, WithPoolSize(4))
+
+	var wg sync.WaitGroup
+	for gid := uint16(1); gid <= 16; gid++ {
+		for level := uint8(0); level < 10; level++ {
+			wg.Add(1)
+			go func(gid uint16, level uint8) {
+				defer wg.Done()
+				assert.NoError(t, c.DirectLevelGroup(gid, level))
+			}(gid, level)
+		}
+	}
+	wg.Wait()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return count == 160
+	}, time.Second, time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	used := map[int]bool{}
+	for gid, nConns := range conns {
+		assert.Len(t, nConns, 1, "group %d", gid)
+		for nConn := range nConns {
+			used[nConn] = true
+		}
+	}
+	assert.Greater(t, len(used), 1)
+}
@@ -0,0 +1,95 @@
+package helvargo
+
+import (
+	"context"
+	"image/color"
+	"time"
+
+	"github.com/nuqz/helvar-go/members"
+	"github.com/nuqz/helvar-go/message"
+)
+
+// API is the query and control surface of a client of a router. It is
+// implemented by Client and by the Fake of package testing, so code, which
+// depends on it, may be tested without a router or even a socket.
+type API interface {
+	Transceive(msg *message.Message) (*message.Message, error)
+	TransceiveContext(
+		ctx context.Context,
+		msg *message.Message,
+	) (*message.Message, error)
+	Command(
+		id message.CommandID,
+		params ...message.Parameter,
+	) (*message.Message, error)
+
+	GetClusters() ([]members.Cluster, error)
+	GetRouters() ([]members.Router, error)
+	GetClusterRouters(cluster uint8) ([]members.Router, error)
+	GetGroups() ([]members.Group, error)
+	GetGroupName(g members.Group) (string, error)
+	GetGroupNames(ids []uint16) ([]string, error)
+	GetDevices(g members.Group) ([]members.Device, error)
+	GetDeviceName(d members.Device) (string, error)
+	GetDeviceNames(addrs []string) ([]string, error)
+	GetDeviceState(d members.Device) (members.DeviceState, error)
+	GetTime() (time.Time, error)
+
+	RecallSceneGroup(
+		gid uint16,
+		block, scene uint8,
+		params ...message.Parameter,
+	) error
+	RecallSceneDevice(
+		addr string,
+		block, scene uint8,
+		params ...message.Parameter,
+	) error
+	DirectLevelGroup(
+		gid uint16,
+		level uint8,
+		params ...message.Parameter,
+	) error
+	DirectLevelDevice(
+		addr string,
+		level uint8,
+		params ...message.Parameter,
+	) error
+	ColorTemperatureGroup(
+		gid, tempK uint16,
+		level uint8,
+		params ...message.Parameter,
+	) error
+	ColorTemperatureDevice(
+		addr string,
+		tempK uint16,
+		level uint8,
+		params ...message.Parameter,
+	) error
+	ColorGroup(
+		gid uint16,
+		color color.Color,
+		level uint8,
+		params ...message.Parameter,
+	) error
+	ColorDevice(
+		addr string,
+		color color.Color,
+		level uint8,
+		params ...message.Parameter,
+	) error
+	RGBGroup(
+		gid uint16,
+		r, g, b byte,
+		level uint8,
+		params ...message.Parameter,
+	) error
+	RGBDevice(
+		addr string,
+		r, g, b byte,
+		level uint8,
+		params ...message.Parameter,
+	) error
+}
+
+var _ API = (*Client)(nil)
@@ -0,0 +1,69 @@
+package helvargo
+
+import (
+	"path"
+	"testing"
+
+	"github.com/nuqz/helvar-go/members"
+	"github.com/nuqz/helvar-go/message"
+	ht "github.com/nuqz/helvar-go/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ API = (*ht.Fake)(nil)
+
+// switchOff is code, which depends on API only.
+func switchOff(api API) error {
+	groups, err := api.GetGroups()
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		if err := api.DirectLevelGroup(g.ID, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestFake(t *testing.T) {
+	fake := ht.NewFake(ht.MustNetFromYAMLFile(
+		path.Join("testing", "test_net.yml")))
+
+	name, err := fake.GetDeviceName(members.Device{Address: "1.251.1"})
+	require.NoError(t, err)
+	assert.Equal(t, "Lamp 1 in Group 11", name)
+
+	routers, err := fake.GetRouters()
+	require.NoError(t, err)
+	assert.Equal(t, []members.Router{
+		{ID: 251, Cluster: 1}, {ID: 252, Cluster: 2}, {ID: 253, Cluster: 3},
+	}, routers)
+
+	fake.Reset()
+	fake.Answer(message.QueryGroups, "1,2")
+	require.NoError(t, switchOff(fake))
+	fake.AssertSent(t,
+		message.NewQueryGroups(),
+		message.NewDirectLevelGroup(1, 0),
+		message.NewDirectLevelGroup(2, 0))
+	assert.Len(t, fake.SentCommands(message.DirectLevelGroup), 2)
+
+	fake.ReplyError(message.QueryGroups, message.EInvalidGroupIndex)
+	err = switchOff(fake)
+	assert.True(t, errors.Is(err, message.EInvalidGroupIndex), err)
+	var replyErr *ReplyError
+	assert.True(t, errors.As(err, &replyErr), err)
+
+	lost := errors.New("connection lost")
+	fake.Fail(message.DirectLevelDevice, lost)
+	assert.Equal(t, lost, fake.DirectLevelDevice("1.251.1", 50))
+	fake.AssertSentMessage(t, message.NewDirectLevelDevice("1.251.1", 50))
+
+	_, err = fake.Command(message.QueryGroup)
+	assert.Error(t, err)
+}
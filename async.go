@@ -0,0 +1,285 @@
+package helvargo
+
+import (
+	"context"
+	"image/color"
+	"strconv"
+	"sync"
+
+	"github.com/nuqz/helvar-go/colour"
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+)
+
+// AsyncQueueSize is the number of asynchronous messages of one target, which
+// may wait to be sent. Messages beyond it fail with ErrAsyncQueueFull.
+const AsyncQueueSize = 1024
+
+// ErrAsyncQueueFull is returned for asynchronous messages to a target, which
+// has too many messages waiting to be sent already.
+var ErrAsyncQueueFull = errors.New("asynchronous queue is full")
+
+// AsyncError is a failure of an asynchronous message, see AsyncErrors.
+type AsyncError struct {
+	Message *message.Message
+	Err     error
+}
+
+func (e *AsyncError) Error() string {
+	return "async " + e.Message.String() + ": " + e.Err.Error()
+}
+
+func (e *AsyncError) Unwrap() error { return e.Err }
+
+// Future is an outcome of an asynchronous message, which is not known yet.
+type Future struct {
+	done  chan struct{}
+	reply *message.Message
+	err   error
+}
+
+func newFuture() *Future { return &Future{done: make(chan struct{})} }
+
+func (f *Future) resolve(reply *message.Message, err error) {
+	f.reply, f.err = reply, err
+	close(f.done)
+}
+
+// Done returns a channel, which is closed once the message is done.
+func (f *Future) Done() <-chan struct{} { return f.done }
+
+// Wait waits for the message to be done and returns its reply, if it is
+// answered. A given context limits the waiting only, the message is sent
+// anyway.
+func (f *Future) Wait(ctx context.Context) (*message.Message, error) {
+	select {
+	case <-f.done:
+		return f.reply, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Err waits for the message to be done and returns its error.
+func (f *Future) Err() error {
+	<-f.done
+	return f.err
+}
+
+// target of a message, asynchronous messages of the same target are sent
+// in order. Messages without a target share the zero one.
+type target struct {
+	group   uint16
+	address string
+}
+
+func targetOf(msg *message.Message) target {
+	return target{group: msg.GetGroupID(), address: msg.GetAddress()}
+}
+
+func (t target) String() string {
+	if t.address != "" {
+		return "@" + t.address
+	}
+
+	return "group " + strconv.Itoa(int(t.group))
+}
+
+type asyncCall struct {
+	ctx    context.Context
+	msg    *message.Message
+	future *Future
+}
+
+// sequencer sends messages of every target one by one, while messages of
+// different targets are sent concurrently. A target has a goroutine only
+// while it has messages waiting.
+type sequencer struct {
+	mu      sync.Mutex
+	pending map[target][]*asyncCall
+}
+
+// enqueue returns false, when the target has too many messages waiting.
+func (s *sequencer) enqueue(call *asyncCall, send func(*asyncCall)) bool {
+	key := targetOf(call.msg)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending == nil {
+		s.pending = map[target][]*asyncCall{}
+	}
+
+	queue, running := s.pending[key]
+	if len(queue) >= AsyncQueueSize {
+		return false
+	}
+	s.pending[key] = append(queue, call)
+	if !running {
+		go s.drain(key, send)
+	}
+
+	return true
+}
+
+func (s *sequencer) drain(key target, send func(*asyncCall)) {
+	for {
+		s.mu.Lock()
+		queue := s.pending[key]
+		if len(queue) == 0 {
+			delete(s.pending, key)
+			s.mu.Unlock()
+			return
+		}
+		call := queue[0]
+		queue[0] = nil
+		s.pending[key] = queue[1:]
+		s.mu.Unlock()
+
+		send(call)
+	}
+}
+
+// TransceiveAsync queues a message and returns right away. The message is
+// sent the same way TransceiveContext does, but only after asynchronous
+// messages of the same group or device queued before it are done, so their
+// order is preserved. Failures are reported by the returned future and by
+// AsyncErrors as well. Messages, which are still queued when the client is
+// closed, fail with ErrNotConnected.
+func (c *Client) TransceiveAsync(
+	ctx context.Context,
+	msg *message.Message,
+) *Future {
+	call := &asyncCall{ctx: ctx, msg: msg, future: newFuture()}
+	if !c.async.enqueue(call, c.sendAsync) {
+		c.failAsync(call, errors.Wrapf(ErrAsyncQueueFull,
+			"%s", targetOf(msg)))
+	}
+
+	return call.future
+}
+
+func (c *Client) sendAsync(call *asyncCall) {
+	reply, err := c.TransceiveContext(call.ctx, call.msg)
+	if err != nil {
+		c.failAsync(call, err)
+		return
+	}
+
+	call.future.resolve(reply, nil)
+}
+
+func (c *Client) failAsync(call *asyncCall, err error) {
+	call.future.resolve(nil, err)
+
+	select {
+	case c.asyncErrs <- &AsyncError{Message: call.msg, Err: err}:
+	default:
+		c.log.Warn("dropped async error", "message", call.msg, "error", err)
+	}
+}
+
+// AsyncErrors returns a stream of failures of asynchronous messages, so
+// callers, which don't wait for futures, may still observe them. Up to
+// ErrorBufferSize failures are buffered, the rest are dropped (and logged).
+// The channel is never closed.
+func (c *Client) AsyncErrors() <-chan error { return c.asyncErrs }
+
+func (c *Client) RecallSceneGroupAsync(
+	gid uint16,
+	block, scene uint8,
+	params ...message.Parameter,
+) *Future {
+	return c.TransceiveAsync(context.Background(),
+		message.NewRecallSceneGroup(gid, block, scene, params...))
+}
+
+func (c *Client) RecallSceneDeviceAsync(
+	addr string,
+	block, scene uint8,
+	params ...message.Parameter,
+) *Future {
+	return c.TransceiveAsync(context.Background(),
+		message.NewRecallSceneDevice(addr, block, scene, params...))
+}
+
+func (c *Client) DirectLevelGroupAsync(
+	gid uint16,
+	level uint8,
+	params ...message.Parameter,
+) *Future {
+	return c.TransceiveAsync(context.Background(),
+		message.NewDirectLevelGroup(gid, level, params...))
+}
+
+func (c *Client) DirectLevelDeviceAsync(
+	addr string,
+	level uint8,
+	params ...message.Parameter,
+) *Future {
+	return c.TransceiveAsync(context.Background(),
+		message.NewDirectLevelDevice(addr, level, params...))
+}
+
+func (c *Client) ColorTemperatureGroupAsync(
+	gid, tempK uint16,
+	level uint8,
+	params ...message.Parameter,
+) *Future {
+	return c.TransceiveAsync(context.Background(),
+		message.NewColorTemperatureGroup(gid, c.clampKelvins(tempK), level,
+			params...))
+}
+
+func (c *Client) ColorTemperatureDeviceAsync(
+	addr string,
+	tempK uint16,
+	level uint8,
+	params ...message.Parameter,
+) *Future {
+	return c.TransceiveAsync(context.Background(),
+		message.NewColorTemperatureDevice(addr, c.clampKelvins(tempK), level,
+			params...))
+}
+
+func (c *Client) ColorGroupAsync(
+	gid uint16,
+	color color.Color,
+	level uint8,
+	params ...message.Parameter,
+) *Future {
+	return c.TransceiveAsync(context.Background(),
+		message.NewColorGroup(gid, c.clampColor(color), level, params...))
+}
+
+func (c *Client) ColorDeviceAsync(
+	addr string,
+	color color.Color,
+	level uint8,
+	params ...message.Parameter,
+) *Future {
+	return c.TransceiveAsync(context.Background(),
+		message.NewColorDevice(addr, c.clampColor(color), level, params...))
+}
+
+func (c *Client) RGBGroupAsync(
+	gid uint16,
+	r, g, b byte,
+	level uint8,
+	params ...message.Parameter,
+) *Future {
+	xy := c.clampColor(colour.FromRGB(r, g, b))
+	return c.TransceiveAsync(context.Background(),
+		message.NewColorGroup(gid, xy, level, params...))
+}
+
+func (c *Client) RGBDeviceAsync(
+	addr string,
+	r, g, b byte,
+	level uint8,
+	params ...message.Parameter,
+) *Future {
+	xy := c.clampColor(colour.FromRGB(r, g, b))
+	return c.TransceiveAsync(context.Background(),
+		message.NewColorDevice(addr, xy, level, params...))
+}
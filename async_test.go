@@ -0,0 +1,100 @@
+package helvargo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransceiveAsync(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		levels = map[uint16][]uint8{}
+	)
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		level, _ := req.GetParameter(message.Level).(uint64)
+		levels[req.GetGroupID()] = append(levels[req.GetGroupID()],
+			uint8(level))
+		return ""
+	})
+
+	futures := []*Future{}
+	for level := uint8(0); level < 50; level++ {
+		futures = append(futures,
+			c.DirectLevelGroupAsync(1, level),
+			c.DirectLevelGroupAsync(2, level))
+	}
+	for _, f := range futures {
+		require.NoError(t, f.Err())
+	}
+
+	// Control commands are done, once they are written.
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(levels[1])+len(levels[2]) == 100
+	}, time.Second, time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for gid := uint16(1); gid <= 2; gid++ {
+		require.Len(t, levels[gid], 50)
+		for i, level := range levels[gid] {
+			assert.Equal(t, uint8(i), level, "group %d", gid)
+		}
+	}
+}
+
+func TestAsyncErrors(t *testing.T) {
+	c := NewClient("localhost")
+
+	f := c.DirectLevelDeviceAsync("1.2.3.4", 50)
+	_, err := f.Wait(context.Background())
+	assert.True(t, errors.Is(err, ErrNotConnected), err)
+
+	err = <-c.AsyncErrors()
+	var asyncErr *AsyncError
+	require.True(t, errors.As(err, &asyncErr), err)
+	assert.Equal(t, message.DirectLevelDevice, asyncErr.Message.GetCommandID())
+	assert.True(t, errors.Is(err, ErrNotConnected), err)
+}
+
+func TestSequencerLimit(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	send := func(call *asyncCall) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		call.future.resolve(nil, nil)
+	}
+	call := func(gid uint16) *asyncCall {
+		return &asyncCall{
+			ctx:    context.Background(),
+			msg:    message.NewDirectLevelGroup(gid, 1),
+			future: newFuture(),
+		}
+	}
+
+	var s sequencer
+	require.True(t, s.enqueue(call(1), send))
+	<-started
+
+	for i := 0; i < AsyncQueueSize; i++ {
+		require.True(t, s.enqueue(call(1), send))
+	}
+	assert.False(t, s.enqueue(call(1), send))
+	assert.True(t, s.enqueue(call(2), send))
+
+	close(release)
+}
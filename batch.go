@@ -0,0 +1,160 @@
+package helvargo
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+)
+
+// BatchResult is an outcome of a message of a batch.
+type BatchResult struct {
+	Message *message.Message
+	Reply   *message.Message
+	Err     error
+}
+
+// BatchResults are outcomes of a batch in order of its messages.
+type BatchResults []BatchResult
+
+// Err returns errors of all failed messages joined, nil if none failed.
+func (rs BatchResults) Err() error {
+	var errs []error
+	for _, r := range rs {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// BatchProgress is reported every time a message of a batch is done.
+type BatchProgress struct {
+	// Index is the index of the message, which is done, and Result is its
+	// outcome.
+	Index  int
+	Result BatchResult
+
+	// Done is the number of messages done so far including failed ones.
+	Done   int
+	Failed int
+	Total  int
+}
+
+// BatchOptions configure a batch, the zero value is fine.
+type BatchOptions struct {
+	// Concurrency is the maximum number of messages in flight, zero means
+	// the pool size of the client, see WithPoolSize.
+	Concurrency int
+
+	// OnProgress is called after every message, if set. Calls are
+	// serialized, so it needs no locking of its own, but it holds up the
+	// batch while running.
+	OnProgress func(BatchProgress)
+}
+
+// Batch sends messages concurrently and waits for all of them. Every
+// message goes through the same path as TransceiveContext does, so failures
+// are retried per message. Messages, which are not sent yet, when a given
+// context is done, fail with its error.
+func (c *Client) Batch(
+	ctx context.Context,
+	msgs []*message.Message,
+	opts BatchOptions,
+) BatchResults {
+	out := make(BatchResults, len(msgs))
+
+	n := opts.Concurrency
+	if n <= 0 {
+		n = c.poolSize
+	}
+	n = min(n, len(msgs))
+
+	var (
+		mu       sync.Mutex
+		progress = BatchProgress{Total: len(msgs)}
+	)
+	report := func(i int) {
+		if opts.OnProgress == nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		progress.Index, progress.Result = i, out[i]
+		progress.Done++
+		if out[i].Err != nil {
+			progress.Failed++
+		}
+		opts.OnProgress(progress)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				out[i].Message = msgs[i]
+				if err := ctx.Err(); err != nil {
+					out[i].Err = errors.Wrapf(err,
+						"batch was cancelled before sending: %s", msgs[i])
+				} else {
+					out[i].Reply, out[i].Err = c.TransceiveContext(ctx, msgs[i])
+				}
+				report(i)
+			}
+		}()
+	}
+
+	for i := range msgs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return out
+}
+
+// answers returns answers of replies of a batch, answers of failed messages
+// are empty.
+func (rs BatchResults) answers() []string {
+	out := make([]string, len(rs))
+	for i, r := range rs {
+		if r.Err == nil && r.Reply != nil {
+			out[i] = r.Reply.Answer
+		}
+	}
+
+	return out
+}
+
+// GetGroupNames returns names of groups with given IDs queried
+// concurrently. Names of groups, which failed to be queried, are empty, and
+// their errors are returned joined.
+func (c *Client) GetGroupNames(ids []uint16) ([]string, error) {
+	msgs := make([]*message.Message, len(ids))
+	for i, id := range ids {
+		msgs[i] = message.NewQueryGroupDescription(id)
+	}
+
+	rs := c.Batch(context.Background(), msgs, BatchOptions{})
+	return rs.answers(), rs.Err()
+}
+
+// GetDeviceNames returns names of devices with given addresses queried
+// concurrently, see GetGroupNames.
+func (c *Client) GetDeviceNames(addrs []string) ([]string, error) {
+	msgs := make([]*message.Message, len(addrs))
+	for i, addr := range addrs {
+		msgs[i] = message.NewQueryDeviceDescription(addr)
+	}
+
+	rs := c.Batch(context.Background(), msgs, BatchOptions{})
+	return rs.answers(), rs.Err()
+}
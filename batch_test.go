@@ -0,0 +1,109 @@
+package helvargo
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	ht "github.com/nuqz/helvar-go/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	var inflight, maxInflight atomic.Int32
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			max := maxInflight.Load()
+			if n <= max || maxInflight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		reply := &message.Message{
+			Type:       message.TReply,
+			Parameters: req.Parameters,
+			Answer:     "Group " + strconv.Itoa(int(req.GetGroupID())),
+		}
+		if req.GetGroupID() == 13 {
+			reply.Type, reply.Answer = message.TError, "1"
+		}
+		return reply.String()
+	}, WithPoolSize(4))
+	c.SetRetryPolicy(NoRetries)
+
+	msgs := []*message.Message{}
+	for gid := uint16(10); gid < 20; gid++ {
+		msgs = append(msgs, message.NewQueryGroupDescription(gid))
+	}
+
+	progress := []BatchProgress{}
+	rs := c.Batch(context.Background(), msgs, BatchOptions{
+		Concurrency: 2,
+		OnProgress:  func(p BatchProgress) { progress = append(progress, p) },
+	})
+
+	require.Len(t, rs, len(msgs))
+	for i, r := range rs {
+		assert.Same(t, msgs[i], r.Message)
+		if msgs[i].GetGroupID() == 13 {
+			var replyErr *ReplyError
+			assert.True(t, errors.As(r.Err, &replyErr), r.Err)
+			continue
+		}
+
+		require.NoError(t, r.Err)
+		assert.Equal(t,
+			"Group "+strconv.Itoa(int(msgs[i].GetGroupID())), r.Reply.Answer)
+	}
+	assert.Error(t, rs.Err())
+	assert.LessOrEqual(t, maxInflight.Load(), int32(2))
+
+	require.Len(t, progress, len(msgs))
+	last := progress[len(progress)-1]
+	assert.Equal(t, len(msgs), last.Done)
+	assert.Equal(t, 1, last.Failed)
+	assert.Equal(t, len(msgs), last.Total)
+}
+
+func TestBatchCancelled(t *testing.T) {
+	c := serveScript(t, replyGroups)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rs := c.Batch(ctx, []*message.Message{
+		message.NewQueryGroups(),
+		message.NewQueryGroups(),
+	}, BatchOptions{})
+	for _, r := range rs {
+		assert.True(t, errors.Is(r.Err, context.Canceled), r.Err)
+	}
+}
+
+func TestGetNames(t *testing.T) {
+	net := ht.MustNetFromYAMLFile(path.Join("testing", "test_net.yml"))
+	router := ht.NewRouter("", net)
+	c := NewClient("localhost", WithConnFactory(router.Dial),
+		WithPoolSize(3))
+	_, err := c.Connect()
+	require.NoError(t, err)
+	defer c.Disconnect()
+
+	names, err := c.GetGroupNames([]uint16{11, 12})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Group 11", "Group 12"}, names)
+
+	names, err = c.GetDeviceNames([]string{"1.251.1", "2.252.1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Lamp 1 in Group 11", "Lamp 1 in Group 12"},
+		names)
+}
@@ -0,0 +1,266 @@
+package helvargo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+)
+
+// ErrRouterUnavailable is returned right away, while the circuit breaker of
+// a client is open.
+var ErrRouterUnavailable = errors.New("router is unavailable")
+
+// BreakerState is a state of a circuit breaker.
+type BreakerState uint8
+
+const (
+	// BreakerClosed lets requests through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects requests with ErrRouterUnavailable.
+	BreakerOpen
+	// BreakerHalfOpen rejects requests, while the router is probed.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerPolicy tells the client when to stop sending requests to a router,
+// which doesn't respond, and when to try again.
+type BreakerPolicy struct {
+	// FailureThreshold is the number of consecutive transport failures,
+	// which open the breaker. Zero disables the breaker. Errors replied by
+	// a router close the breaker just like successes, since the router
+	// responds.
+	FailureThreshold int
+
+	// OpenDuration is a delay before a half-open breaker probes the router
+	// with a QueryTime request. The breaker is closed, once the router
+	// replies, or opened for another OpenDuration otherwise.
+	OpenDuration time.Duration
+
+	// ProbeTimeout limits the duration of a probe.
+	ProbeTimeout time.Duration
+
+	// OnStateChange is called on every change of the state of the breaker,
+	// if set. It is called with the breaker locked, so it must not block or
+	// call the client.
+	OnStateChange func(from, to BreakerState)
+}
+
+// DefaultBreakerPolicy opens the breaker after 5 consecutive failures and
+// probes the router every 5 seconds then. The breaker is disabled by
+// default, see SetBreakerPolicy.
+var DefaultBreakerPolicy = BreakerPolicy{
+	FailureThreshold: 5,
+	OpenDuration:     5 * time.Second,
+	ProbeTimeout:     2 * time.Second,
+}
+
+// NoBreaker disables the circuit breaker, it is the default.
+var NoBreaker = BreakerPolicy{}
+
+type breaker struct {
+	policy BreakerPolicy
+	probe  func(ctx context.Context) error
+	report func(from, to BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	timer    *time.Timer
+	stopped  bool
+}
+
+// allow returns nil, when a request may be sent.
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerClosed {
+		return errors.Wrapf(ErrRouterUnavailable, "circuit breaker is %s",
+			b.state)
+	}
+
+	return nil
+}
+
+// record accounts an outcome of a request.
+func (b *breaker) record(ctx context.Context, err error) {
+	if b == nil {
+		return
+	}
+
+	var replyErr *ReplyError
+	switch {
+	case err == nil, errors.As(err, &replyErr):
+		b.mu.Lock()
+		b.failures = 0
+		b.mu.Unlock()
+	case ctx.Err() != nil, errors.Is(err, ErrNotConnected),
+		errors.Is(err, ErrRouterUnavailable), errors.Is(err, ErrRateLimited):
+		// Neither of them tells anything about the router.
+	default:
+		b.mu.Lock()
+		b.failures++
+		if b.state == BreakerClosed &&
+			b.failures >= b.policy.FailureThreshold {
+			b.open()
+		}
+		b.mu.Unlock()
+	}
+}
+
+// open opens the breaker and schedules a probe, b.mu must be held.
+func (b *breaker) open() {
+	b.setState(BreakerOpen)
+	if b.stopped {
+		return
+	}
+
+	b.timer = time.AfterFunc(b.policy.OpenDuration, b.halfOpen)
+}
+
+func (b *breaker) halfOpen() {
+	b.mu.Lock()
+	if b.stopped || b.state != BreakerOpen {
+		b.mu.Unlock()
+		return
+	}
+	b.setState(BreakerHalfOpen)
+	b.mu.Unlock()
+
+	ctx := context.Background()
+	if b.policy.ProbeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.policy.ProbeTimeout)
+		defer cancel()
+	}
+	err := b.probe(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerHalfOpen {
+		return
+	}
+
+	if err != nil {
+		b.open()
+		return
+	}
+
+	b.failures = 0
+	b.setState(BreakerClosed)
+}
+
+// setState changes the state and reports it, b.mu must be held.
+func (b *breaker) setState(to BreakerState) {
+	from := b.state
+	if from == to {
+		return
+	}
+
+	b.state = to
+	b.report(from, to)
+}
+
+// reset closes the breaker, e.g. when the client is connected again.
+func (b *breaker) reset() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.failures = 0
+	b.stopped = false
+	b.setState(BreakerClosed)
+}
+
+// stop cancels scheduled probes for good.
+func (b *breaker) stop() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// current returns the state of the breaker.
+func (b *breaker) current() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// SetBreakerPolicy sets the policy of the circuit breaker of the client, it
+// is disabled by default. The breaker starts closed.
+func (c *Client) SetBreakerPolicy(p BreakerPolicy) {
+	var b *breaker
+	if p.FailureThreshold > 0 {
+		b = &breaker{policy: p, probe: c.probe}
+		b.report = func(from, to BreakerState) {
+			if to == BreakerOpen {
+				c.log.Warn("circuit breaker opened", "from", from,
+					"failures", b.failures)
+			} else {
+				c.log.Info("circuit breaker state changed",
+					"from", from, "to", to)
+			}
+			if p.OnStateChange != nil {
+				p.OnStateChange(from, to)
+			}
+		}
+	}
+
+	c.breaker.Swap(b).stop()
+}
+
+// WithBreaker sets the policy of the circuit breaker of the client, see
+// SetBreakerPolicy.
+func WithBreaker(p BreakerPolicy) Option {
+	return func(c *Client) { c.SetBreakerPolicy(p) }
+}
+
+// probe sends the keep alive query of high priority bypassing the breaker.
+func (c *Client) probe(ctx context.Context) error {
+	_, err := c.attempt(WithPriority(ctx, PriorityHigh), 0,
+		message.NewQueryTime())
+	if err != nil {
+		c.log.Warn("router probe failed", "error", err)
+	}
+	return err
+}
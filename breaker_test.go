@@ -0,0 +1,97 @@
+package helvargo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuqz/helvar-go/members"
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	var (
+		down     atomic.Bool
+		nQueries atomic.Int32
+	)
+	down.Store(true)
+
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		nQueries.Add(1)
+		if down.Load() {
+			return ""
+		}
+		return (&message.Message{
+			Type:       message.TReply,
+			Parameters: req.Parameters,
+			Answer:     "1",
+		}).String()
+	})
+	c.SetRetryPolicy(NoRetries)
+
+	changes := make(chan [2]BreakerState, 8)
+	c.SetBreakerPolicy(BreakerPolicy{
+		FailureThreshold: 2,
+		OpenDuration:     20 * time.Millisecond,
+		ProbeTimeout:     time.Second,
+		OnStateChange: func(from, to BreakerState) {
+			changes <- [2]BreakerState{from, to}
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := c.GetGroups()
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, ErrRouterUnavailable), err)
+	}
+	assert.Equal(t, [2]BreakerState{BreakerClosed, BreakerOpen}, <-changes)
+	assert.Equal(t, BreakerOpen, c.Stats().Breaker)
+
+	queried := nQueries.Load()
+	_, err := c.GetGroups()
+	assert.True(t, errors.Is(err, ErrRouterUnavailable), err)
+	assert.Equal(t, queried, nQueries.Load())
+
+	// Probe fails while the router is down.
+	assert.Equal(t, [2]BreakerState{BreakerOpen, BreakerHalfOpen}, <-changes)
+	assert.Equal(t, [2]BreakerState{BreakerHalfOpen, BreakerOpen}, <-changes)
+
+	down.Store(false)
+	for change := range changes {
+		if change[1] == BreakerClosed {
+			assert.Equal(t, BreakerHalfOpen, change[0])
+			break
+		}
+	}
+
+	_, err = c.GetGroups()
+	require.NoError(t, err)
+	assert.Equal(t, BreakerClosed, c.Stats().Breaker)
+}
+
+func TestBreakerIgnoresCallerErrors(t *testing.T) {
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		return "!V:1,C:105,G:1=1#"
+	})
+	c.SetBreakerPolicy(BreakerPolicy{
+		FailureThreshold: 1,
+		OpenDuration:     time.Hour,
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := c.GetGroupName(members.Group{ID: 1})
+		var replyErr *ReplyError
+		require.ErrorAs(t, err, &replyErr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.TransceiveContext(ctx, message.NewQueryGroups())
+	require.Error(t, err)
+
+	assert.Equal(t, BreakerClosed, c.Stats().Breaker)
+}
@@ -1,14 +1,19 @@
 package helvargo
 
 import (
-	"fmt"
+	"context"
+	stderrors "errors"
 	"image/color"
+	"log/slog"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nuqz/chanfan"
+	"github.com/nuqz/helvar-go/colour"
 	"github.com/nuqz/helvar-go/members"
 	"github.com/nuqz/helvar-go/message"
 	"github.com/pkg/errors"
@@ -16,84 +21,688 @@ import (
 
 // Client is a HelvarNET client.
 type Client struct {
-	host      string
-	hostParts []string
-	port      int
-	address   string
-	connected bool
+	address string
+
+	// routerID is the identity of the router, once it is known, routerHint
+	// is its part given by WithRouterID, see Identify.
+	routerID   atomic.Pointer[RouterID]
+	routerHint RouterID
+
+	log *slog.Logger
+
+	// Settings made by options, they are applied by Connect.
+	poolSize     int
+	queueSize    int
+	keepAlive    time.Duration
+	probeMessage func() *message.Message
+	dialTimeout  time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	transport    Transport
+	dialer       Dialer
+	connFactory  ConnFactory
+
+	// mu guards the lifecycle: the state and everything created by Connect.
+	// Calls hold it for reading only while they register in inflight, so
+	// Close can wait for them without holding it.
+	mu       sync.RWMutex
+	state    state
+	inflight sync.WaitGroup
+	lifetime context.Context
+	abort    context.CancelFunc
+	// closing is closed, when Close starts, so messages held back by
+	// interceptors are sent right away, see CoalescingInterceptor.
+	closing      chan struct{}
+	queues       pool
+	transceivers []*Transceiver
+
+	eventsMu sync.Mutex
+	events   *listener
+
+	gamut   atomic.Pointer[colour.Gamut]
+	retry   atomic.Pointer[RetryPolicy]
+	limiter atomic.Pointer[limiter]
+	breaker atomic.Pointer[breaker]
+
+	limiterStats limiterStats
+
+	async     sequencer
+	asyncErrs chan error
+
+	interceptorsMu sync.Mutex
+	interceptors   []Interceptor
+	invoke         atomic.Pointer[Invoker]
+}
+
+type state uint8
+
+const (
+	stateDisconnected state = iota
+	stateConnecting
+	stateConnected
+	stateClosing
+)
+
+var (
+	// ErrNotConnected is returned for calls of a client, which is not
+	// connected, is being connected or closed.
+	ErrNotConnected = errors.New("client is not connected")
+
+	// ErrAlreadyConnected is returned by Connect of a client, which is not
+	// disconnected. A closed client may be connected again.
+	ErrAlreadyConnected = errors.New("client is already connected")
 
-	toSend chan<- *chanfan.IO[*message.Message, *message.Message]
+	// ErrInvalidOption is returned by Connect of a client configured with
+	// an invalid option, e.g. a pool of no connections.
+	ErrInvalidOption = errors.New("invalid option")
+)
+
+// ErrorBufferSize is a capacity of channels returned by Connect. Errors are
+// dropped (and logged) when a channel is full.
+const ErrorBufferSize = 16
+
+// NewClient returns new client, which will communicate to a router at a
+// given address, e.g. "10.254.1.2", "router.example.com:50000" or
+// "[fd00::2]". The default port depends on the transport, see
+// WithTransport. Host names are resolved every time a connection is
+// established, so reconnects follow changes of DNS records.
+func NewClient(addr string, opts ...Option) *Client {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// There is no port.
+		host, port = strings.Trim(addr, "[]"), ""
+	}
+	c := &Client{
+		log:          discardLogger,
+		poolSize:     DefaultPoolSize,
+		queueSize:    DefaultQueueSize,
+		keepAlive:    KeepAliveDuration,
+		probeMessage: message.NewQueryTime,
+		dialer:       &net.Dialer{},
+		asyncErrs:    make(chan error, ErrorBufferSize),
+	}
+	c.SetRetryPolicy(DefaultRetryPolicy)
+	c.SetRateLimits(NoRateLimits)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if port == "" {
+		port = strconv.Itoa(c.transport.defaultPort())
+	}
+	c.address = net.JoinHostPort(host, port)
+	c.log = c.log.With("router", c.address)
+	c.Use()
+
+	return c
+}
+
+// Use appends interceptors to the chain every message sent by the client
+// passes through. The first interceptor ever added is the outermost one.
+func (c *Client) Use(interceptors ...Interceptor) {
+	c.interceptorsMu.Lock()
+	defer c.interceptorsMu.Unlock()
+
+	c.interceptors = append(c.interceptors, interceptors...)
+	invoke := chain(c.transceive, c.interceptors...)
+	c.invoke.Store(&invoke)
 }
 
-// NewClient returns new client, which will communicate to specified router.
-func NewClient(host string, port int) *Client {
-	if host == "localhost" {
-		host = "127.0.0.1"
+// SetRetryPolicy sets the policy of retrying failed requests, by default it
+// is DefaultRetryPolicy.
+func (c *Client) SetRetryPolicy(p RetryPolicy) {
+	c.retry.Store(&p)
+}
+
+// Address returns the address of the router, the client communicates to.
+func (c *Client) Address() string { return c.address }
+
+// Stats is a snapshot of the state of a client's transceiver pool.
+type Stats struct {
+	// QueueDepth is the number of messages waiting for a free transceiver.
+	QueueDepth int
+	// QueueCapacity is the number of messages, which may wait for
+	// transceivers without blocking callers, in all priority lanes of all
+	// of them.
+	QueueCapacity int
+	// Transceivers is the number of running transceivers.
+	Transceivers int
+	// Reconnects is the total number of connections re-established after a
+	// reset.
+	Reconnects int64
+	// KeepAliveFailures is the total number of failed keep alive probes.
+	KeepAliveFailures int64
+	// LimiterWaits is the number of requests delayed by the rate limiter
+	// and LimiterWaitTime is the total time they waited.
+	LimiterWaits    int64
+	LimiterWaitTime time.Duration
+	// RateLimited is the number of requests rejected by the rate limiter.
+	RateLimited int64
+	// Breaker is the state of the circuit breaker.
+	Breaker BreakerState
+}
+
+// Stats returns current state of the client's transceiver pool.
+func (c *Client) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := Stats{
+		LimiterWaits:    c.limiterStats.waits.Load(),
+		LimiterWaitTime: time.Duration(c.limiterStats.waitTime.Load()),
+		RateLimited:     c.limiterStats.rejected.Load(),
+		Breaker:         c.breaker.Load().current(),
 	}
 
-	address := fmt.Sprintf("%s:%d", host, port)
-	return &Client{
-		host:      host,
-		hostParts: strings.Split(host, "."),
-		port:      port,
-		address:   address,
+	out.QueueDepth = c.queues.depth()
+	out.QueueCapacity = c.queues.capacity()
+
+	for _, t := range c.transceivers {
+		if t.IsAlive() {
+			out.Transceivers++
+		}
+		out.Reconnects += t.Reconnects()
+		out.KeepAliveFailures += t.KeepAliveFailures()
+	}
+
+	return out
+}
+
+// SetGamut sets the gamut of devices controlled by this client. Colours and
+// colour temperatures sent by ColorXxx, RGBXxx and ColorTemperatureXxx
+// methods are clamped to it. Nil gamut disables clamping.
+func (c *Client) SetGamut(g *colour.Gamut) {
+	c.gamut.Store(g)
+}
+
+func (c *Client) clampColor(col color.Color) colour.XY {
+	xy := colour.FromColor(col)
+	if g := c.gamut.Load(); g != nil {
+		return g.Clamp(xy)
+	}
+
+	return xy
+}
+
+func (c *Client) clampKelvins(k uint16) uint16 {
+	if g := c.gamut.Load(); g != nil {
+		return g.ClampKelvins(k)
 	}
+
+	return k
 }
 
-// IsSameSubnet returns true when ... TODO
+// IsSameSubnet returns true when a device with a given address is behind
+// the router the client communicates to. It is false, while the identity of
+// the router is unknown, see Identify.
 func (c *Client) IsSameSubnet(addr string) bool {
-	addrParts := strings.Split(addr, ".")
-	return addrParts[0] == c.hostParts[2] && addrParts[1] == c.hostParts[3]
+	key, ok := c.routerKey()
+	return ok && routerKeyOf(addr) == key
 }
 
-// Connect ... TODO ...
-func (c *Client) Connect(nTransceivers, bufSize int) ([]<-chan error, error) {
-	in := make(chan *chanfan.IO[*message.Message, *message.Message], bufSize)
-	errs := make([]<-chan error, bufSize)
-	c.toSend = in
+// validate checks settings made by options.
+func (c *Client) validate() error {
+	if c.poolSize < 1 {
+		return errors.Wrapf(ErrInvalidOption,
+			"pool size must be positive, got %d", c.poolSize)
+	}
+	if c.queueSize < 0 {
+		return errors.Wrapf(ErrInvalidOption,
+			"queue size must not be negative, got %d", c.queueSize)
+	}
+
+	return nil
+}
+
+// Connect establishes connections to the router, requests are sent over
+// them concurrently, see WithPoolSize and WithQueueSize. Returned channels
+// deliver failures of keep alive probes of every connection, they are closed
+// when the client is closed.
+//
+// Connect of a client, which is already connected, fails with
+// ErrAlreadyConnected. A closed client may be connected again. Invalid
+// options are reported with ErrInvalidOption.
+func (c *Client) Connect() ([]<-chan error, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.state != stateDisconnected {
+		c.mu.Unlock()
+		return nil, errors.Wrapf(ErrAlreadyConnected, "%s", c.address)
+	}
+	c.state = stateConnecting
+	c.mu.Unlock()
+
+	// Every transceiver reads an unbuffered channel of its own scheduler,
+	// so the scheduler picks the next request only when it is free.
+	nTransceivers := c.poolSize
+	queues := make(pool, 0, nTransceivers)
+	transceivers := make([]*Transceiver, 0, nTransceivers)
 	for i := 0; i < nTransceivers; i++ {
-		conn, err := net.Dial("tcp", c.address)
+		in := make(chan queueItem)
+		t, err := NewTransceiver(c.dial, in)
 		if err != nil {
+			for _, t := range transceivers {
+				// The error of connecting matters, not this one.
+				_ = t.Terminate()
+			}
+
+			c.mu.Lock()
+			c.state = stateDisconnected
+			c.mu.Unlock()
+
 			return nil, errors.Wrapf(err,
 				"couldn't establish connection #%d to %s", i+1, c.address)
 		}
+		t.log = c.log.With("transceiver", i+1)
+		t.KeepAliveDuration = c.keepAlive
+		t.probe = c.probeMessage
+		t.readTimeout, t.writeTimeout = c.readTimeout, c.writeTimeout
+
+		transceivers = append(transceivers, t)
+		queues = append(queues, newScheduler(c.queueSize, in))
+	}
+
+	errs := make([]<-chan error, nTransceivers)
+	for i, t := range transceivers {
+		errs[i] = c.forwardErrors(t)
+	}
 
-		errs[i] = NewTransceiver(conn, in).Go()
+	for _, queue := range queues {
+		go queue.run()
 	}
 
-	c.connected = true
+	c.mu.Lock()
+	c.lifetime, c.abort = context.WithCancel(context.Background())
+	c.closing = make(chan struct{})
+	c.queues = queues
+	c.transceivers = transceivers
+	c.state = stateConnected
+	c.mu.Unlock()
+
+	c.breaker.Load().reset()
+
+	c.log.Info("connected", "transceivers", nTransceivers)
 	return errs, nil
 }
 
+// forwardErrors starts a transceiver and returns a buffered channel of its
+// errors. The transceiver doesn't stall, even if nobody reads them.
+func (c *Client) forwardErrors(t *Transceiver) <-chan error {
+	in := t.Go()
+	out := make(chan error, ErrorBufferSize)
+	go func() {
+		defer close(out)
+		for err := range in {
+			select {
+			case out <- err:
+			default:
+				t.log.Warn("dropped transceiver error", "error", err)
+			}
+		}
+	}()
+
+	return out
+}
+
+func (c *Client) dial() (net.Conn, error) {
+	ctx := context.Background()
+	if c.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.dialTimeout)
+		defer cancel()
+	}
+
+	if c.connFactory != nil {
+		return c.connFactory(ctx)
+	}
+
+	return c.dialer.DialContext(ctx, c.transport.String(), c.address)
+}
+
+// Close disconnects the client gracefully. New calls are rejected with
+// ErrNotConnected right away, while calls in flight are completed, changes
+// held back by CoalescingInterceptor are sent without a delay. Then
+// connections are closed. When a given context is done before that, calls
+// in flight are cancelled and the context error is returned along with
+// errors of closing connections, if any. Close of a client, which is not
+// connected, does nothing.
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.state != stateConnected {
+		c.mu.Unlock()
+		return nil
+	}
+	c.state = stateClosing
+	close(c.closing)
+	c.mu.Unlock()
+
+	c.breaker.Load().stop()
+
+	var errs []error
+
+	c.eventsMu.Lock()
+	if c.events != nil {
+		// Subscribers are notified by closed channels.
+		if err := c.events.close(); err != nil {
+			errs = append(errs, errors.Wrap(err,
+				"failed to close listener connection properly"))
+		}
+		c.events = nil
+	}
+	c.eventsMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		c.abort()
+		<-drained
+		errs = append(errs, errors.Wrap(ctx.Err(),
+			"calls in flight were cancelled"))
+	}
+
+	// Nobody sends to queues anymore, transceivers terminate, once they
+	// are drained.
+	c.queues.close()
+
+wait:
+	for _, t := range c.transceivers {
+		select {
+		case <-t.terminated:
+			if t.terminateErr != nil {
+				errs = append(errs, t.terminateErr)
+			}
+		case <-ctx.Done():
+			c.abort()
+			errs = append(errs, errors.Wrap(ctx.Err(),
+				"transceivers were not terminated"))
+			break wait
+		}
+	}
+	c.abort()
+
+	c.mu.Lock()
+	c.state = stateDisconnected
+	c.mu.Unlock()
+
+	c.log.Info("disconnected")
+	return stderrors.Join(errs...)
+}
+
+// Disconnect is Close without a deadline, its error is logged.
 func (c *Client) Disconnect() {
-	close(c.toSend)
-	c.connected = false
+	if err := c.Close(context.Background()); err != nil {
+		c.log.Error("failed to disconnect properly", "error", err)
+	}
+}
+
+// inflightKey marks contexts of calls registered in flight, the value is
+// the client.
+type inflightKey struct{}
+
+// acquire registers a call in flight and returns its marked context, a call
+// must be released, when it is done. Close waits for calls in flight,
+// before it closes connections.
+func (c *Client) acquire(ctx context.Context) (context.Context, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.state != stateConnected {
+		return ctx, errors.Wrapf(ErrNotConnected, "%s", c.address)
+	}
+
+	c.inflight.Add(1)
+	return context.WithValue(ctx, inflightKey{}, c), nil
+}
+
+// closingOf returns a channel, which is closed when the client of a call
+// registered in flight starts closing. It is nil for other calls.
+func closingOf(ctx context.Context) <-chan struct{} {
+	c, ok := ctx.Value(inflightKey{}).(*Client)
+	if !ok {
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.closing
+}
+
+// connection returns queues to send a call to and the context of the
+// lifetime of the current connection. Calls registered in flight are sent
+// while the client is closing as well, since queues are closed only after
+// they are done.
+func (c *Client) connection(ctx context.Context) (
+	pool,
+	context.Context,
+	error,
+) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.state != stateConnected && (c.state != stateClosing ||
+		ctx.Value(inflightKey{}) != c) {
+		return nil, nil, errors.Wrapf(ErrNotConnected, "%s", c.address)
+	}
+
+	return c.queues, c.lifetime, nil
+}
+
+func (c *Client) release() { c.inflight.Done() }
+
+func (c *Client) isConnected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.state == stateConnected
 }
 
+// Subscribe returns a channel of events, which happen in the network behind
+// this client's back: scenes recalled from panels, levels changed by other
+// clients, etc. Only events accepted by a filter are delivered, nil filter
+// accepts everything. Events are read from a dedicated connection opened on
+// the first subscription.
+//
+// The channel is closed when the returned cancel function is called or the
+// client is disconnected. Events are dropped when the channel is full.
+func (c *Client) Subscribe(filter EventFilter) (<-chan Event, func(), error) {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+
+	// The state is checked under eventsMu, so Close either sees the new
+	// listener and closes it or this call sees the client closing.
+	if !c.isConnected() {
+		return nil, nil, errors.Wrapf(ErrNotConnected, "%s", c.address)
+	}
+
+	if c.events == nil {
+		l, err := newListener(c.dial, c.log.With("listener", true))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err,
+				"couldn't establish listener connection to %s", c.address)
+		}
+		c.events = l
+	}
+
+	events, cancel := c.events.subscribe(filter)
+	return events, cancel, nil
+}
+
+// Transceive sends a message to the router and returns its reply, if the
+// message is answered. It's the same as TransceiveContext with background
+// context.
 func (c *Client) Transceive(msg *message.Message) (*message.Message, error) {
-	if !c.connected {
-		return nil, errors.Errorf("client is not connected: %s", c.address)
+	return c.TransceiveContext(context.Background(), msg)
+}
+
+// TransceiveContext sends a message to the router and returns its reply, if
+// the message is answered. The call is limited by the deadline of a given
+// context, failed attempts are retried according to the retry policy. The
+// message passes through interceptors (see Use) first.
+func (c *Client) TransceiveContext(
+	ctx context.Context,
+	msg *message.Message,
+) (*message.Message, error) {
+	// The call is in flight while it passes interceptors as well, so Close
+	// waits for messages held back by them, see CoalescingInterceptor.
+	// Calls of a client, which is not connected, still pass interceptors,
+	// they fail at the end of the chain.
+	if registered, err := c.acquire(ctx); err == nil {
+		defer c.release()
+		ctx = registered
+	}
+
+	return (*c.invoke.Load())(ctx, msg)
+}
+
+func (c *Client) transceive(
+	ctx context.Context,
+	msg *message.Message,
+) (*message.Message, error) {
+	if _, _, err := c.connection(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := message.Validate(msg); err != nil {
+		return nil, errors.Wrap(err, "refused to send invalid message")
+	}
+
+	policy := c.retry.Load()
+	attempts := policy.attempts(ctx, msg)
+	breaker := c.breaker.Load()
+	for attempt := 1; ; attempt++ {
+		if err := breaker.allow(); err != nil {
+			return nil, errors.Wrapf(err, "%s", c.address)
+		}
+
+		if err := c.limiter.Load().wait(ctx, msg); err != nil {
+			return nil, err
+		}
+
+		reply, err := c.attempt(ctx, policy.AttemptTimeout, msg)
+		breaker.record(ctx, err)
+		if err == nil {
+			return reply, nil
+		}
+
+		var replyErr *ReplyError
+		if attempt >= attempts || ctx.Err() != nil ||
+			errors.As(err, &replyErr) || errors.Is(err, ErrNotConnected) {
+			if attempt > 1 {
+				return nil, errors.Wrapf(err, "gave up after %d attempts",
+					attempt)
+			}
+			return nil, err
+		}
+
+		backoff := policy.Backoff(attempt)
+		c.log.Warn("retrying request", "message", msg, "attempt", attempt,
+			"backoff", backoff, "error", err)
+		if err := sleep(ctx, backoff); err != nil {
+			return nil, errors.Wrapf(err,
+				"failed to transceive message: %s", msg)
+		}
+	}
+}
+
+func (c *Client) attempt(
+	ctx context.Context,
+	timeout time.Duration,
+	msg *message.Message,
+) (reply *message.Message, err error) {
+	queues, lifetime, err := c.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil && lifetime.Err() != nil {
+			err = errors.Wrapf(ErrNotConnected,
+				"client was closed during the call: %s", err)
+		}
+	}()
+
+	// The call is cancelled, when the client is closed forcibly.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(lifetime, cancel)()
+
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	queue := queues.pick(msg)
+	queue.load.Add(1)
+	defer queue.load.Add(-1)
+
+	// The result is buffered, so a transceiver doesn't block on it when
+	// the caller has already gone.
+	ret := make(chan *chanfan.Result[*message.Message], 1)
+	req := NewRequest(ctx, msg)
+	io := chanfan.NewIO(req, ret)
+	select {
+	case queue.lane(priorityOf(ctx, msg)) <- io:
+	case <-ctx.Done():
+		return nil, errors.Wrapf(ctx.Err(),
+			"failed to queue message: %s", msg)
 	}
 
-	ret := make(chan *chanfan.Result[*message.Message])
-	c.toSend <- chanfan.NewIO(msg, ret)
-	resp := <-ret
+	var resp *chanfan.Result[*message.Message]
+	select {
+	case resp = <-ret:
+	case <-ctx.Done():
+		// The message may be being written right now, it must not be
+		// released before the transceiver is done with it.
+		req.abandon()
+		return nil, errors.Wrapf(ctx.Err(),
+			"failed to transceive message: %s", msg)
+	}
 
 	if resp.Error != nil {
 		return nil, errors.Wrap(resp.Error, "failed to transceive message")
 	}
 
 	if resp.Value != nil && resp.Value.Type == message.TError {
-		// TODO: add error description by it's code
-		return nil, errors.Errorf("received a message with an error: %s",
-			resp.Value)
+		return nil, message.NewReplyError(resp.Value)
 	}
 
 	return resp.Value, nil
 }
 
+// Command sends a command with a given ID and parameters, its version is
+// taken from the command registry. Returns a reply for answered commands and
+// nil otherwise.
+func (c *Client) Command(
+	id message.CommandID,
+	params ...message.Parameter,
+) (*message.Message, error) {
+	msg, err := message.New(id, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Transceive(msg)
+}
+
 func (c *Client) queryIDs(msg *message.Message) ([]int, error) {
-	msg, err := c.Transceive(msg)
+	return c.queryIDsContext(context.Background(), msg)
+}
+
+func (c *Client) queryIDsContext(
+	ctx context.Context,
+	msg *message.Message,
+) ([]int, error) {
+	msg, err := c.TransceiveContext(ctx, msg)
 	if err != nil {
 		return nil, err
 	}
@@ -120,15 +729,37 @@ func (c *Client) GetClusters() ([]members.Cluster, error) {
 	return clusters, nil
 }
 
+// GetRouters returns routers of all clusters of the workgroup, see
+// GetClusterRouters.
 func (c *Client) GetRouters() ([]members.Router, error) {
-	routerIDs, err := c.queryIDs(message.NewQueryRouters("0"))
+	clusters, err := c.GetClusters()
+	if err != nil {
+		return nil, err
+	}
+
+	routers := []members.Router{}
+	for _, cluster := range clusters {
+		clusterRouters, err := c.GetClusterRouters(cluster.ID)
+		if err != nil {
+			return nil, err
+		}
+		routers = append(routers, clusterRouters...)
+	}
+
+	return routers, nil
+}
+
+// GetClusterRouters returns routers of a given cluster.
+func (c *Client) GetClusterRouters(cluster uint8) ([]members.Router, error) {
+	routerIDs, err := c.queryIDs(
+		message.NewQueryRouters(strconv.Itoa(int(cluster))))
 	if err != nil {
 		return nil, err
 	}
 
 	routers := make([]members.Router, len(routerIDs))
 	for i, id := range routerIDs {
-		routers[i] = members.Router{ID: uint8(id)}
+		routers[i] = members.Router{ID: uint8(id), Cluster: cluster}
 	}
 
 	return routers, nil
@@ -169,16 +800,11 @@ func (c *Client) GetDevices(g members.Group) ([]members.Device, error) {
 		return nil, err
 	}
 
+	// Groups may span several routers, devices behind other routers are
+	// returned as well. Use Workgroup to reach them.
 	devices := []members.Device{}
-	for _, rawAddr := range msg.AnswerStrings() {
-		addr := strings.TrimLeft(rawAddr, "@")
-
-		// TODO: ...
-		// Only lookup devices with same cluster and router ID as current
-		// router
-		// if c.IsSameSubnet(addr) {
+	for _, addr := range msg.AnswerAddresses() {
 		devices = append(devices, members.Device{Address: addr})
-		// }
 	}
 
 	return devices, nil
@@ -197,8 +823,17 @@ func (c *Client) GetDeviceName(d members.Device) (string, error) {
 }
 
 func (c *Client) GetDeviceState(d members.Device) (members.DeviceState, error) {
-	// TODO
-	return 0, errors.New("not implemented")
+	msg, err := c.Transceive(message.NewQueryDeviceState(d.Address))
+	if err != nil {
+		return 0, err
+	}
+
+	state, err := msg.AnswerInt()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to query state of %s", d.Address)
+	}
+
+	return members.DeviceState(state), nil
 }
 
 func (c *Client) GetTime() (time.Time, error) {
@@ -207,24 +842,36 @@ func (c *Client) GetTime() (time.Time, error) {
 		return time.Time{}, err
 	}
 
-	ts, err := strconv.ParseInt(reply.Answer, 10, 64)
+	ts, err := reply.AnswerInt()
 	if err != nil {
-		return time.Time{}, errors.Wrapf(err,
-			"failed to query network time - timestamp %s is invalid integer",
-			reply.Answer)
+		return time.Time{}, errors.Wrap(err,
+			"failed to query network time")
 	}
 
 	return time.Unix(ts, 0), nil
 }
 
+// control sends a control command built by set in a pooled message, it is
+// reused after the call, interceptors must not keep it, see Interceptor.
+func (c *Client) control(set func(*message.Message) *message.Message) error {
+	msg := message.Acquire()
+	_, err := c.Transceive(set(msg))
+	if err == nil {
+		// Errors may refer to the message (see DesyncError), so only
+		// messages of successful calls are given back.
+		message.Release(msg)
+	}
+	return err
+}
+
 func (c *Client) RecallSceneGroup(
 	gid uint16,
 	block, scene uint8,
 	params ...message.Parameter,
 ) error {
-	_, err := c.Transceive(
-		message.NewRecallSceneGroup(gid, block, scene, params...))
-	return err
+	return c.control(func(msg *message.Message) *message.Message {
+		return msg.SetRecallSceneGroup(gid, block, scene, params...)
+	})
 }
 
 func (c *Client) RecallSceneDevice(
@@ -232,9 +879,9 @@ func (c *Client) RecallSceneDevice(
 	block, scene uint8,
 	params ...message.Parameter,
 ) error {
-	_, err := c.Transceive(
-		message.NewRecallSceneDevice(addr, block, scene, params...))
-	return err
+	return c.control(func(msg *message.Message) *message.Message {
+		return msg.SetRecallSceneDevice(addr, block, scene, params...)
+	})
 }
 
 func (c *Client) DirectLevelGroup(
@@ -242,9 +889,9 @@ func (c *Client) DirectLevelGroup(
 	level uint8,
 	params ...message.Parameter,
 ) error {
-	_, err := c.Transceive(
-		message.NewDirectLevelGroup(gid, level, params...))
-	return err
+	return c.control(func(msg *message.Message) *message.Message {
+		return msg.SetDirectLevelGroup(gid, level, params...)
+	})
 }
 
 func (c *Client) DirectLevelDevice(
@@ -252,9 +899,9 @@ func (c *Client) DirectLevelDevice(
 	level uint8,
 	params ...message.Parameter,
 ) error {
-	_, err := c.Transceive(
-		message.NewDirectLevelDevice(addr, level, params...))
-	return err
+	return c.control(func(msg *message.Message) *message.Message {
+		return msg.SetDirectLevelDevice(addr, level, params...)
+	})
 }
 
 func (c *Client) ColorTemperatureGroup(
@@ -262,9 +909,10 @@ func (c *Client) ColorTemperatureGroup(
 	level uint8,
 	params ...message.Parameter,
 ) error {
-	_, err := c.Transceive(
-		message.NewColorTemperatureGroup(gid, tempK, level, params...))
-	return err
+	return c.control(func(msg *message.Message) *message.Message {
+		return msg.SetColorTemperatureGroup(gid, c.clampKelvins(tempK),
+			level, params...)
+	})
 }
 
 func (c *Client) ColorTemperatureDevice(
@@ -273,9 +921,10 @@ func (c *Client) ColorTemperatureDevice(
 	level uint8,
 	params ...message.Parameter,
 ) error {
-	_, err := c.Transceive(
-		message.NewColorTemperatureDevice(addr, tempK, level, params...))
-	return err
+	return c.control(func(msg *message.Message) *message.Message {
+		return msg.SetColorTemperatureDevice(addr, c.clampKelvins(tempK),
+			level, params...)
+	})
 }
 
 func (c *Client) ColorGroup(
@@ -284,9 +933,10 @@ func (c *Client) ColorGroup(
 	level uint8,
 	params ...message.Parameter,
 ) error {
-	_, err := c.Transceive(
-		message.NewColorGroup(gid, color, level, params...))
-	return err
+	return c.control(func(msg *message.Message) *message.Message {
+		return msg.SetColorGroup(gid, c.clampColor(color), level,
+			params...)
+	})
 }
 
 func (c *Client) ColorDevice(
@@ -295,9 +945,10 @@ func (c *Client) ColorDevice(
 	level uint8,
 	params ...message.Parameter,
 ) error {
-	_, err := c.Transceive(
-		message.NewColorDevice(addr, color, level, params...))
-	return err
+	return c.control(func(msg *message.Message) *message.Message {
+		return msg.SetColorDevice(addr, c.clampColor(color), level,
+			params...)
+	})
 }
 
 func (c *Client) RGBGroup(
@@ -307,9 +958,10 @@ func (c *Client) RGBGroup(
 	params ...message.Parameter,
 
 ) error {
-	_, err := c.Transceive(
-		message.NewRGBGroup(gid, r, g, b, level, params...))
-	return err
+	xy := c.clampColor(colour.FromRGB(r, g, b))
+	return c.control(func(msg *message.Message) *message.Message {
+		return msg.SetColorGroup(gid, xy, level, params...)
+	})
 }
 
 func (c *Client) RGBDevice(
@@ -318,7 +970,8 @@ func (c *Client) RGBDevice(
 	level uint8,
 	params ...message.Parameter,
 ) error {
-	_, err := c.Transceive(
-		message.NewRGBDevice(addr, r, g, b, level, params...))
-	return err
+	xy := c.clampColor(colour.FromRGB(r, g, b))
+	return c.control(func(msg *message.Message) *message.Message {
+		return msg.SetColorDevice(addr, xy, level, params...)
+	})
 }
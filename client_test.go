@@ -1,32 +1,31 @@
 package helvargo
 
 import (
-	"fmt"
+	"context"
 	"path"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/nuqz/chanfan"
+	"github.com/nuqz/helvar-go/members"
 	"github.com/nuqz/helvar-go/message"
 	ht "github.com/nuqz/helvar-go/testing"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-var (
-	fakeSrvPort = 49876
-)
-
 func TestClient(t *testing.T) {
 	defaultNet := ht.MustNetFromYAMLFile(path.Join("testing", "test_net.yml"))
-	fakeSrv := ht.NewRouter(fmt.Sprintf(":%d", fakeSrvPort), defaultNet)
-	require.NoError(t, fakeSrv.Listen())
+	fakeSrv := ht.NewRouter("", defaultNet)
 
-	client := NewClient("localhost", fakeSrvPort)
+	client := NewClient("localhost", WithConnFactory(fakeSrv.Dial),
+		WithPoolSize(4), WithQueueSize(4))
 	_, err := client.Transceive(message.NewQueryTime())
 	assert.Error(t, err)
 
-	errs, err := client.Connect(4, 4)
+	errs, err := client.Connect()
 	require.NoError(t, err)
 
 	go func() {
@@ -113,3 +112,240 @@ func TestClient(t *testing.T) {
 	_, err = client.Transceive(message.NewQueryTime())
 	assert.Error(t, err)
 }
+
+func TestClientBatch(t *testing.T) {
+	defaultNet := ht.MustNetFromYAMLFile(path.Join("testing", "test_net.yml"))
+	fakeSrv := ht.NewRouter("", defaultNet)
+
+	client := NewClient("localhost", WithConnFactory(fakeSrv.Dial),
+		WithPoolSize(4))
+	_, err := client.Connect()
+	require.NoError(t, err)
+	defer client.Disconnect()
+
+	groups, err := client.GetGroups()
+	require.NoError(t, err)
+
+	ids := make([]uint16, len(groups))
+	for i, group := range groups {
+		ids[i] = group.ID
+	}
+	names, err := client.GetGroupNames(ids)
+	require.NoError(t, err)
+	require.Len(t, names, len(groups))
+
+	for i, group := range groups {
+		name, err := client.GetGroupName(group)
+		require.NoError(t, err)
+		assert.Equal(t, name, names[i])
+
+		devs, err := client.GetDevices(group)
+		require.NoError(t, err)
+
+		addrs := make([]string, len(devs))
+		for i, dev := range devs {
+			addrs[i] = dev.Address
+		}
+		devNames, err := client.GetDeviceNames(addrs)
+		require.NoError(t, err)
+		require.Len(t, devNames, len(devs))
+
+		for i, dev := range devs {
+			name, err := client.GetDeviceName(dev)
+			require.NoError(t, err)
+			assert.Equal(t, name, devNames[i])
+		}
+	}
+}
+
+func TestClientSubscribe(t *testing.T) {
+	defaultNet := ht.MustNetFromYAMLFile(path.Join("testing", "test_net.yml"))
+	fakeSrv := ht.NewRouter("", defaultNet)
+
+	subscriber := NewClient("localhost", WithConnFactory(fakeSrv.Dial))
+	_, err := subscriber.Connect()
+	require.NoError(t, err)
+	defer subscriber.Disconnect()
+
+	events, cancel, err := subscriber.Subscribe(GroupEvents(11))
+	require.NoError(t, err)
+	defer cancel()
+
+	publisher := NewClient("localhost", WithConnFactory(fakeSrv.Dial))
+	_, err = publisher.Connect()
+	require.NoError(t, err)
+	defer publisher.Disconnect()
+
+	require.Eventually(t, func() bool { return fakeSrv.Clients() == 3 },
+		time.Second, 10*time.Millisecond)
+
+	require.NoError(t, publisher.DirectLevelGroup(12, 10))
+	require.NoError(t, publisher.RecallSceneGroup(11, 1, 2))
+	require.NoError(t, publisher.DirectLevelGroup(11, 50))
+
+	// Subscriber's own requests must not be disturbed by unsolicited
+	// messages written to its connections.
+	_, err = subscriber.GetGroupName(members.Group{ID: 11})
+	require.NoError(t, err)
+
+	e := <-events
+	require.IsType(t, SceneRecalled{}, e)
+	assert.Equal(t, uint16(11), e.Group())
+	assert.Equal(t, uint8(2), e.(SceneRecalled).Scene)
+
+	e = <-events
+	require.IsType(t, LevelChanged{}, e)
+	assert.Equal(t, uint8(50), e.(LevelChanged).Level)
+
+	fakeSrv.Broadcast(message.NewColorTemperatureGroup(11, 2700, 80))
+	e = <-events
+	require.IsType(t, ColorTemperatureChanged{}, e)
+	assert.Equal(t, uint16(370), e.(ColorTemperatureChanged).Mireds)
+}
+
+func TestClientSubscribeWhileClosing(t *testing.T) {
+	fakeSrv := ht.NewRouter("", ht.Network{})
+
+	for i := 0; i < 16; i++ {
+		c := NewClient("localhost", WithConnFactory(fakeSrv.Dial))
+		_, err := c.Connect()
+		require.NoError(t, err)
+
+		subscribed := make(chan (<-chan Event), 1)
+		go func() {
+			events, _, err := c.Subscribe(nil)
+			if err != nil {
+				assert.True(t, errors.Is(err, ErrNotConnected), err)
+			}
+			subscribed <- events
+		}()
+		// Vary the moment of closing relative to subscribing.
+		time.Sleep(time.Duration(i) * 100 * time.Microsecond)
+		require.NoError(t, c.Close(context.Background()))
+
+		// A listener opened during Close must be closed by it as well.
+		if events := <-subscribed; events != nil {
+			for range events {
+			}
+		}
+	}
+
+	require.Eventually(t, func() bool { return fakeSrv.Clients() == 0 },
+		time.Second, 10*time.Millisecond)
+}
+
+func TestClientConnectTwice(t *testing.T) {
+	c := serveScript(t, replyGroups, WithPoolSize(3))
+
+	_, err := c.Connect()
+	assert.True(t, errors.Is(err, ErrAlreadyConnected), err)
+
+	require.NoError(t, c.Close(context.Background()))
+	_, err = c.GetGroups()
+	assert.True(t, errors.Is(err, ErrNotConnected), err)
+	require.NoError(t, c.Close(context.Background()))
+
+	errs, err := c.Connect()
+	require.NoError(t, err)
+	assert.Len(t, errs, 3)
+	for _, errs := range errs {
+		assert.NotNil(t, errs)
+	}
+
+	_, err = c.GetGroups()
+	require.NoError(t, err)
+}
+
+func TestClientCloseDrainsCalls(t *testing.T) {
+	received := make(chan struct{}, 1)
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		received <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+		return "?V:1,C:165=1,2,3#"
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := c.GetGroups()
+		done <- err
+	}()
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, c.Close(ctx))
+	assert.NoError(t, <-done)
+	assert.Zero(t, c.Stats().Transceivers)
+}
+
+func TestClientCloseCancelsCalls(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	received := make(chan struct{}, 1)
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		received <- struct{}{}
+		<-block
+		return ""
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := c.GetGroups()
+		done <- err
+	}()
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		20*time.Millisecond)
+	defer cancel()
+	err := c.Close(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	err = <-done
+	assert.True(t, errors.Is(err, ErrNotConnected), err)
+}
+
+func TestClientTimeoutReleasesMessage(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		<-block
+		return ""
+	})
+
+	msg := message.Acquire().SetType(message.TCommand).AddParameters(
+		message.Parameter{ID: message.Version, Value: uint8(1)},
+		message.Parameter{ID: message.Command, Value: message.QueryGroups})
+	ctx, cancel := context.WithTimeout(context.Background(),
+		20*time.Millisecond)
+	defer cancel()
+	_, err := c.TransceiveContext(ctx, msg)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	// The transceiver must be done with the message, the race detector
+	// complains otherwise.
+	message.Release(msg)
+}
+
+func TestClientConcurrentClose(t *testing.T) {
+	c := serveScript(t, replyGroups)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, err := c.GetGroups(); err != nil {
+					assert.True(t, errors.Is(err, ErrNotConnected), err)
+					return
+				}
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, c.Close(context.Background()))
+	wg.Wait()
+}
@@ -0,0 +1,200 @@
+package helvargo
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+)
+
+// family of a coalesced command: commands of the same family override each
+// other, while commands of different families may be combined.
+type family uint8
+
+const (
+	familyLevel family = iota
+	familyColour
+	familyColourTemperature
+)
+
+// coalesceKey identifies commands, of which only the latest one matters.
+type coalesceKey struct {
+	command message.CommandID
+	family  family
+	group   uint16
+	address string
+}
+
+// coalesceKeyOf returns the key of a message and true, if the message may
+// be coalesced. Only absolute level, colour and colour temperature changes
+// are coalesced: applying the latest of them has the same effect as
+// applying all of them in order.
+func coalesceKeyOf(msg *message.Message) (coalesceKey, bool) {
+	key := coalesceKey{command: msg.GetCommandID()}
+	switch key.command {
+	case message.DirectLevelGroup:
+		key.group = msg.GetGroupID()
+	case message.DirectLevelDevice:
+		key.address = msg.GetAddress()
+	default:
+		return key, false
+	}
+
+	switch {
+	case msg.GetParameter(message.ColourX) != nil:
+		key.family = familyColour
+	case msg.GetParameter(message.Mireds) != nil:
+		key.family = familyColourTemperature
+	default:
+		key.family = familyLevel
+	}
+
+	return key, true
+}
+
+// pending is the latest message of some key waiting for a flush along with
+// callers of all messages it has replaced.
+type pending struct {
+	seq  uint64
+	ctx  context.Context
+	msg  *message.Message
+	next Invoker
+
+	done  chan struct{}
+	reply *message.Message
+	err   error
+}
+
+type coalescer struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	seq     uint64
+	pending map[coalesceKey]*pending
+	timer   *time.Timer
+
+	// flushMu keeps flushes in order, when sending takes longer than the
+	// interval.
+	flushMu sync.Mutex
+}
+
+// CoalescingInterceptor returns an interceptor, which delays absolute level,
+// colour and colour temperature changes of groups and devices for up to a
+// given interval and sends only the latest change of each kind per target.
+// Callers of replaced commands receive the outcome of the command, which
+// replaced them. Queries, scene recalls and other messages pass through
+// right away, but pending changes of their group or device are sent before
+// them.
+//
+// It is meant for rapid changes of the same target, e.g. dragging a slider,
+// when intermediate states are stale by the time they would be applied.
+// Pending changes are sent even if their callers give up waiting, so their
+// messages are still in use after cancelled calls return. Client.Close
+// sends pending changes of its calls right away and waits for them.
+func CoalescingInterceptor(interval time.Duration) Interceptor {
+	c := &coalescer{
+		interval: interval,
+		pending:  map[coalesceKey]*pending{},
+	}
+
+	return c.intercept
+}
+
+func (c *coalescer) intercept(
+	ctx context.Context,
+	msg *message.Message,
+	next Invoker,
+) (*message.Message, error) {
+	key, ok := coalesceKeyOf(msg)
+	if !ok {
+		// The message must not overtake pending changes of its target.
+		group, address := msg.GetGroupID(), msg.GetAddress()
+		if group != 0 || address != "" {
+			c.flushTarget(group, address)
+		}
+		return next(ctx, msg)
+	}
+
+	c.mu.Lock()
+	c.seq++
+	p, ok := c.pending[key]
+	if !ok {
+		p = &pending{done: make(chan struct{})}
+		c.pending[key] = p
+	}
+	// The flush shouldn't be interrupted, when the latest caller gives up,
+	// since callers of replaced messages wait for it as well.
+	p.seq, p.ctx, p.msg, p.next = c.seq, context.WithoutCancel(ctx), msg, next
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.interval, c.flush)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return p.reply, p.err
+	case <-closingOf(ctx):
+		// The client waits for calls in flight, when it is closed, so
+		// there is no point in waiting for the interval.
+		c.flush()
+		<-p.done
+		return p.reply, p.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flush sends all pending messages.
+func (c *coalescer) flush() {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	c.timer = nil
+	batch := c.take(func(coalesceKey) bool { return true })
+	c.mu.Unlock()
+
+	sendPending(batch)
+}
+
+// flushTarget sends pending messages of a group or a device right away and
+// waits for them.
+func (c *coalescer) flushTarget(group uint16, address string) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	batch := c.take(func(key coalesceKey) bool {
+		return key.group == group && key.address == address
+	})
+	c.mu.Unlock()
+
+	sendPending(batch)
+}
+
+// take removes pending messages of matching keys, c.mu must be held.
+func (c *coalescer) take(match func(coalesceKey) bool) []*pending {
+	batch := []*pending{}
+	for key, p := range c.pending {
+		if match(key) {
+			batch = append(batch, p)
+			delete(c.pending, key)
+		}
+	}
+
+	return batch
+}
+
+// sendPending sends messages in order of their latest updates.
+func sendPending(batch []*pending) {
+	sort.Slice(batch, func(i, j int) bool {
+		return batch[i].seq < batch[j].seq
+	})
+
+	for _, p := range batch {
+		p.reply, p.err = p.next(p.ctx, p.msg)
+		close(p.done)
+	}
+}
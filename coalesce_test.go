@@ -0,0 +1,175 @@
+package helvargo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalesceKey(t *testing.T) {
+	for tcDescription, tc := range map[string]struct {
+		msg         *message.Message
+		expected    coalesceKey
+		coalescable bool
+	}{
+		"level of group": {
+			msg: message.NewDirectLevelGroup(1, 50),
+			expected: coalesceKey{
+				command: message.DirectLevelGroup,
+				family:  familyLevel,
+				group:   1,
+			},
+			coalescable: true,
+		},
+		"colour of device": {
+			msg: message.NewRGBDevice("1.2.3.4", 255, 0, 0, 50),
+			expected: coalesceKey{
+				command: message.DirectLevelDevice,
+				family:  familyColour,
+				address: "1.2.3.4",
+			},
+			coalescable: true,
+		},
+		"colour temperature of group": {
+			msg: message.NewColorTemperatureGroup(2, 2700, 50),
+			expected: coalesceKey{
+				command: message.DirectLevelGroup,
+				family:  familyColourTemperature,
+				group:   2,
+			},
+			coalescable: true,
+		},
+		"scene recall": {
+			msg: message.NewRecallSceneGroup(1, 1, 1),
+		},
+		"query": {
+			msg: message.NewQueryGroupDescription(1),
+		},
+	} {
+		key, ok := coalesceKeyOf(tc.msg)
+		assert.Equal(t, tc.coalescable, ok, tcDescription)
+		if tc.coalescable {
+			assert.Equal(t, tc.expected, key, tcDescription)
+		}
+	}
+}
+
+func TestCoalescing(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		mu.Lock()
+		received = append(received, req.String())
+		mu.Unlock()
+		return "?V:1,C:165=1,2,3#"
+	})
+	c.Use(CoalescingInterceptor(100 * time.Millisecond))
+
+	var wg sync.WaitGroup
+	send := func(msg *message.Message) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Transceive(msg)
+			assert.NoError(t, err)
+		}()
+		// Keep the order of sends.
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for level := uint8(10); level <= 50; level += 10 {
+		send(message.NewDirectLevelGroup(1, level))
+	}
+	send(message.NewColorTemperatureGroup(1, 2700, 60))
+	send(message.NewDirectLevelGroup(2, 10))
+	send(message.NewDirectLevelGroup(1, 70))
+
+	// Queries aren't delayed.
+	_, err := c.GetGroups()
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, []string{message.NewQueryGroups().String()}, received)
+	mu.Unlock()
+
+	wg.Wait()
+	// Control commands aren't answered, so they may be still on their way.
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 4
+	}, time.Second, time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		message.NewQueryGroups().String(),
+		message.NewColorTemperatureGroup(1, 2700, 60).String(),
+		message.NewDirectLevelGroup(2, 10).String(),
+		message.NewDirectLevelGroup(1, 70).String(),
+	}, received)
+}
+
+func TestCoalescingFlushesTarget(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		mu.Lock()
+		received = append(received, req.String())
+		mu.Unlock()
+		return ""
+	})
+	c.Use(CoalescingInterceptor(time.Hour))
+
+	done := make(chan error)
+	go func() { done <- c.DirectLevelGroup(5, 80) }()
+	// Let the level change get pending, the interval never passes.
+	time.Sleep(10 * time.Millisecond)
+
+	require.NoError(t, c.RecallSceneGroup(5, 1, 16))
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 2
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, <-done)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		message.NewDirectLevelGroup(5, 80).String(),
+		message.NewRecallSceneGroup(5, 1, 16).String(),
+	}, received)
+}
+
+func TestCoalescingFlushesOnClose(t *testing.T) {
+	received := make(chan string, 1)
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		select {
+		case received <- req.String():
+		default:
+		}
+		return ""
+	})
+	c.Use(CoalescingInterceptor(time.Hour))
+
+	done := make(chan error)
+	go func() { done <- c.DirectLevelGroup(5, 80) }()
+	// Let the level change get pending, the interval never passes.
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, c.Close(ctx))
+	require.NoError(t, <-done)
+	assert.Equal(t, message.NewDirectLevelGroup(5, 80).String(), <-received)
+}
@@ -0,0 +1,65 @@
+package colour
+
+import "math"
+
+const (
+	// MinKelvins and MaxKelvins bound the range, where the Planckian locus
+	// approximation used by this package is valid.
+	MinKelvins = 1667
+	MaxKelvins = 25000
+)
+
+// KelvinsToMireds converts colour temperature to micro reciprocal degrees,
+// which are used by HelvarNET colour temperature commands.
+func KelvinsToMireds(k uint16) int {
+	// The formula was taken from one of Helvar manuals
+	return int(math.Round(1000000 / float64(k)))
+}
+
+// MiredsToKelvins converts micro reciprocal degrees to colour temperature.
+func MiredsToKelvins(m uint16) uint16 {
+	if m == 0 {
+		return 0
+	}
+
+	return uint16(math.Min(math.MaxUint16, math.Round(1000000/float64(m))))
+}
+
+// FromKelvins returns the chromaticity of a black body radiator of a given
+// temperature, i.e. a point on the Planckian locus. Temperature is clamped
+// to [MinKelvins..MaxKelvins] range.
+//
+// Cubic spline approximation by Kim et al. is used.
+func FromKelvins(k float64) XY {
+	t := math.Min(MaxKelvins, math.Max(MinKelvins, k))
+	t2, t3 := t*t, t*t*t
+
+	var x float64
+	if t <= 4000 {
+		x = -0.2661239e9/t3 - 0.2343589e6/t2 + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/t3 + 2.1070379e6/t2 + 0.2226347e3/t + 0.240390
+	}
+
+	x2, x3 := x*x, x*x*x
+
+	var y float64
+	switch {
+	case t <= 2222:
+		y = -1.1063814*x3 - 1.34811020*x2 + 2.18555832*x - 0.20219683
+	case t <= 4000:
+		y = -0.9549476*x3 - 1.37418593*x2 + 2.09137015*x - 0.16748867
+	default:
+		y = 3.0817580*x3 - 5.87338670*x2 + 3.75112997*x - 0.37001483
+	}
+
+	return XY{x, y}
+}
+
+// Kelvins returns correlated colour temperature of the chromaticity
+// calculated with McCamy's approximation. It is accurate for points close to
+// the Planckian locus only.
+func (xy XY) Kelvins() float64 {
+	n := (xy.X - 0.3320) / (0.1858 - xy.Y)
+	return 449*n*n*n + 3525*n*n + 6823.3*n + 5520.33
+}
@@ -0,0 +1,229 @@
+// Package colour provides conversions between colour spaces used by HelvarNET
+// colour commands (CIE 1931 xy chromaticity and correlated colour
+// temperature) and the ones used by humans and displays (RGB, HSV, hex).
+//
+// RGB conversions use the same wide gamut RGB matrix and sRGB companding as
+// github.com/nuqz/col2xy, so the xy sent to fixtures matches what is shown
+// back to a user.
+package colour
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// XY is a point in CIE 1931 xy chromaticity space. It implements
+// color.Color at full brightness, so it may be passed wherever color.Color
+// is expected, e.g. to display a swatch of a colour sent to fixtures.
+type XY struct {
+	X, Y float64
+}
+
+// rgb2xyz converts linear wide gamut RGB into CIE XYZ.
+var rgb2xyz = [3][3]float64{
+	{0.6491852651246980, 0.1034883891428110, 0.1973263457324920},
+	{0.2340599935483600, 0.7433166037561910, 0.0226234026954449},
+	{0.0, 0.0530940431254422, 1.0369059568745600},
+}
+
+// xyz2rgb is the inverse of rgb2xyz.
+var xyz2rgb = invert(rgb2xyz)
+
+func invert(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+
+	var out [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			// Cofactor of m[j][i] gives the (i, j) element of the adjugate.
+			r0, r1 := (j+1)%3, (j+2)%3
+			c0, c1 := (i+1)%3, (i+2)%3
+			out[i][j] = (m[r0][c0]*m[r1][c1] - m[r0][c1]*m[r1][c0]) / det
+		}
+	}
+
+	return out
+}
+
+// White is the chromaticity of equal RGB components.
+var White = whitePoint()
+
+func whitePoint() XY {
+	var X, Y, Z float64
+	for i := 0; i < 3; i++ {
+		X += rgb2xyz[0][i]
+		Y += rgb2xyz[1][i]
+		Z += rgb2xyz[2][i]
+	}
+
+	sum := X + Y + Z
+	return XY{X / sum, Y / sum}
+}
+
+func expand(c float64) float64 {
+	if c > 0.04045 {
+		return math.Pow((c+0.055)/(1+0.055), 2.4)
+	}
+
+	return c / 12.92
+}
+
+func compress(c float64) float64 {
+	if c > 0.0031308 {
+		return 1.055*math.Pow(c, 1/2.4) - 0.055
+	}
+
+	return c * 12.92
+}
+
+// FromLinear returns the chromaticity of linear RGB components within
+// [0..1] range. Black is mapped to White, since it has no chromaticity.
+func FromLinear(r, g, b float64) XY {
+	X := r*rgb2xyz[0][0] + g*rgb2xyz[0][1] + b*rgb2xyz[0][2]
+	Y := r*rgb2xyz[1][0] + g*rgb2xyz[1][1] + b*rgb2xyz[1][2]
+	Z := r*rgb2xyz[2][0] + g*rgb2xyz[2][1] + b*rgb2xyz[2][2]
+
+	sum := X + Y + Z
+	if sum == 0 {
+		return White
+	}
+
+	return XY{X / sum, Y / sum}
+}
+
+// FromRGB returns the chromaticity of 8-bit gamma compressed RGB.
+func FromRGB(r, g, b uint8) XY {
+	return FromLinear(
+		expand(float64(r)/0xff),
+		expand(float64(g)/0xff),
+		expand(float64(b)/0xff))
+}
+
+// FromColor returns the chromaticity of any color.Color.
+func FromColor(c color.Color) XY {
+	if xy, ok := c.(XY); ok {
+		return xy
+	}
+
+	r, g, b, _ := c.RGBA()
+	return FromLinear(
+		expand(float64(r)/0xffff),
+		expand(float64(g)/0xffff),
+		expand(float64(b)/0xffff))
+}
+
+// FromHSV returns the chromaticity of a colour given by hue in degrees and
+// saturation within [0..1] range. Value (brightness) doesn't affect
+// chromaticity, in HelvarNET it is a level sent alongside.
+func FromHSV(h, s float64) XY {
+	r, g, b := HSVToRGB(h, s, 1)
+	return FromRGB(r, g, b)
+}
+
+// HSVToRGB converts hue in degrees, saturation and value within [0..1]
+// range into 8-bit RGB.
+func HSVToRGB(h, s, v float64) (r, g, b uint8) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	s, v = clamp01(s), clamp01(v)
+
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var rf, gf, bf float64
+	switch {
+	case h < 60:
+		rf, gf, bf = c, x, 0
+	case h < 120:
+		rf, gf, bf = x, c, 0
+	case h < 180:
+		rf, gf, bf = 0, c, x
+	case h < 240:
+		rf, gf, bf = 0, x, c
+	case h < 300:
+		rf, gf, bf = x, 0, c
+	default:
+		rf, gf, bf = c, 0, x
+	}
+
+	return toByte(rf + m), toByte(gf + m), toByte(bf + m)
+}
+
+// ParseHex returns the chromaticity of a colour in "#rrggbb" or "#rgb"
+// notation, leading "#" is optional.
+func ParseHex(s string) (XY, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{
+			hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	if len(hex) != 6 {
+		return XY{}, errors.Errorf("%q is not a hex colour", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return XY{}, errors.Wrapf(err, "%q is not a hex colour", s)
+	}
+
+	return FromRGB(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+}
+
+// Linear returns linear RGB components of the chromaticity at full
+// brightness, i.e. the largest component is 1. Components of colours out of
+// the RGB gamut are clipped to 0.
+func (xy XY) Linear() (r, g, b float64) {
+	y := math.Max(xy.Y, 1e-9)
+	X, Z := xy.X/y, (1-xy.X-xy.Y)/y
+
+	r = math.Max(0, X*xyz2rgb[0][0]+xyz2rgb[0][1]+Z*xyz2rgb[0][2])
+	g = math.Max(0, X*xyz2rgb[1][0]+xyz2rgb[1][1]+Z*xyz2rgb[1][2])
+	b = math.Max(0, X*xyz2rgb[2][0]+xyz2rgb[2][1]+Z*xyz2rgb[2][2])
+
+	if m := math.Max(r, math.Max(g, b)); m > 0 {
+		r, g, b = r/m, g/m, b/m
+	}
+
+	return r, g, b
+}
+
+// RGB returns 8-bit gamma compressed RGB of the chromaticity at full
+// brightness.
+func (xy XY) RGB() (r, g, b uint8) {
+	rl, gl, bl := xy.Linear()
+	return toByte(compress(rl)), toByte(compress(gl)), toByte(compress(bl))
+}
+
+// RGBA implements color.Color.
+func (xy XY) RGBA() (r, g, b, a uint32) {
+	r8, g8, b8 := xy.RGB()
+	return color.RGBA{r8, g8, b8, 0xff}.RGBA()
+}
+
+// Hex returns the chromaticity at full brightness in "#rrggbb" notation.
+func (xy XY) Hex() string {
+	r, g, b := xy.RGB()
+	const digits = "0123456789abcdef"
+	return string([]byte{'#',
+		digits[r>>4], digits[r&0xf],
+		digits[g>>4], digits[g&0xf],
+		digits[b>>4], digits[b&0xf]})
+}
+
+func clamp01(v float64) float64 {
+	return math.Min(1, math.Max(0, v))
+}
+
+func toByte(v float64) uint8 {
+	return uint8(math.Round(clamp01(v) * 0xff))
+}
@@ -0,0 +1,110 @@
+package colour
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rgbTestCase struct {
+	r, g, b uint8
+	xy      XY
+}
+
+func TestFromRGB(t *testing.T) {
+	// Expected values are the ones produced by github.com/nuqz/col2xy.
+	testCases := map[string]rgbTestCase{
+		"red":   {0xff, 0x00, 0x00, XY{0.735, 0.265}},
+		"green": {0x00, 0xff, 0x00, XY{0.115, 0.826}},
+		"blue":  {0x00, 0x00, 0xff, XY{0.157, 0.018}},
+		"white": {0xff, 0xff, 0xff, XY{0.3125, 0.3289}},
+		"black": {0x00, 0x00, 0x00, White},
+	}
+
+	for tcDescription, tc := range testCases {
+		t.Run(tcDescription, func(t *testing.T) {
+			xy := FromRGB(tc.r, tc.g, tc.b)
+			assert.InDelta(t, tc.xy.X, xy.X, 1e-4)
+			assert.InDelta(t, tc.xy.Y, xy.Y, 1e-4)
+
+			r, g, b := xy.RGB()
+			if tc.r|tc.g|tc.b != 0 {
+				assert.Equal(t, []uint8{tc.r, tc.g, tc.b}, []uint8{r, g, b})
+			}
+		})
+	}
+}
+
+func TestXYIsColor(t *testing.T) {
+	var c color.Color = FromRGB(0xff, 0x80, 0x00)
+	assert.Equal(t, FromRGB(0xff, 0x80, 0x00), FromColor(c))
+
+	r, g, b, a := c.RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	assert.InDelta(t, 0x8080, g, 0x101)
+	assert.Equal(t, uint32(0), b)
+	assert.Equal(t, uint32(0xffff), a)
+}
+
+func TestParseHex(t *testing.T) {
+	xy, err := ParseHex("#ff0000")
+	require.NoError(t, err)
+	assert.Equal(t, FromRGB(0xff, 0, 0), xy)
+
+	xy, err = ParseHex("0f0")
+	require.NoError(t, err)
+	assert.Equal(t, FromRGB(0, 0xff, 0), xy)
+	assert.Equal(t, "#00ff00", xy.Hex())
+
+	for _, bad := range []string{"", "#ff00", "#gg0000"} {
+		_, err = ParseHex(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestFromHSV(t *testing.T) {
+	assert.Equal(t, FromRGB(0xff, 0, 0), FromHSV(0, 1))
+	assert.Equal(t, FromRGB(0, 0, 0xff), FromHSV(240, 1))
+	assert.Equal(t, FromRGB(0xff, 0, 0), FromHSV(-360, 1))
+	assert.Equal(t, White, FromHSV(123, 0))
+}
+
+func TestKelvins(t *testing.T) {
+	for _, k := range []float64{1800, 2700, 4000, 6500, 10000} {
+		xy := FromKelvins(k)
+		assert.InEpsilon(t, k, xy.Kelvins(), 0.02, k)
+	}
+
+	// D65 is close to 6504 K.
+	d65 := FromKelvins(6504)
+	assert.InDelta(t, 0.3135, d65.X, 1e-3)
+	assert.InDelta(t, 0.3237, d65.Y, 1e-3)
+
+	assert.Equal(t, 370, KelvinsToMireds(2700))
+	assert.Equal(t, uint16(2703), MiredsToKelvins(370))
+	assert.Equal(t, uint16(0), MiredsToKelvins(0))
+}
+
+func TestGamutClamp(t *testing.T) {
+	inside := XY{0.3, 0.3}
+	assert.True(t, SRGBGamut.Contains(inside))
+	assert.Equal(t, inside, SRGBGamut.Clamp(inside))
+
+	// Wide gamut red is out of sRGB, the closest point is sRGB red.
+	red := SRGBGamut.Clamp(WideGamut.Red)
+	assert.InDelta(t, SRGBGamut.Red.X, red.X, 1e-9)
+	assert.InDelta(t, SRGBGamut.Red.Y, red.Y, 1e-9)
+
+	// A point beyond the red-green edge is projected on it.
+	edge := SRGBGamut.Clamp(XY{0.5, 0.5})
+	assert.False(t, SRGBGamut.Contains(XY{0.5, 0.5}))
+	assert.InDelta(t, 0, cross(SRGBGamut.Red, SRGBGamut.Green, edge), 1e-9)
+
+	g := Gamut{MinKelvins: 2700, MaxKelvins: 6500}
+	assert.Equal(t, uint16(2700), g.ClampKelvins(2000))
+	assert.Equal(t, uint16(6500), g.ClampKelvins(10000))
+	assert.Equal(t, uint16(4000), g.ClampKelvins(4000))
+	assert.Equal(t, uint16(10000), Gamut{}.ClampKelvins(10000))
+}
@@ -0,0 +1,93 @@
+package colour
+
+import "math"
+
+// Gamut describes colours a device is able to produce: a triangle of its
+// primaries in xy space and, for tunable white devices, a range of colour
+// temperatures. Zero MinKelvins or MaxKelvins means the range is not bounded
+// from that side.
+type Gamut struct {
+	Red, Green, Blue XY
+
+	MinKelvins, MaxKelvins uint16
+}
+
+// WideGamut is the gamut of wide gamut RGB used for conversions by this
+// package.
+var WideGamut = Gamut{
+	Red:   XY{0.7350, 0.2650},
+	Green: XY{0.1150, 0.8260},
+	Blue:  XY{0.1570, 0.0180},
+}
+
+// SRGBGamut is the gamut of sRGB displays.
+var SRGBGamut = Gamut{
+	Red:   XY{0.6400, 0.3300},
+	Green: XY{0.3000, 0.6000},
+	Blue:  XY{0.1500, 0.0600},
+}
+
+func cross(o, a, b XY) float64 {
+	return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+}
+
+// Contains returns true when the chromaticity is within the gamut triangle.
+func (g Gamut) Contains(xy XY) bool {
+	d1 := cross(g.Red, g.Green, xy)
+	d2 := cross(g.Green, g.Blue, xy)
+	d3 := cross(g.Blue, g.Red, xy)
+
+	hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+	hasPos := d1 > 0 || d2 > 0 || d3 > 0
+	return !(hasNeg && hasPos)
+}
+
+func closestOnSegment(a, b, p XY) XY {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lenSq := dx*dx + dy*dy
+	if lenSq == 0 {
+		return a
+	}
+
+	t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / lenSq
+	t = clamp01(t)
+	return XY{a.X + t*dx, a.Y + t*dy}
+}
+
+func distance(a, b XY) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
+
+// Clamp returns the chromaticity itself when it is within the gamut,
+// otherwise the closest point on the gamut triangle is returned.
+func (g Gamut) Clamp(xy XY) XY {
+	if g.Contains(xy) {
+		return xy
+	}
+
+	best := closestOnSegment(g.Red, g.Green, xy)
+	for _, p := range []XY{
+		closestOnSegment(g.Green, g.Blue, xy),
+		closestOnSegment(g.Blue, g.Red, xy),
+	} {
+		if distance(p, xy) < distance(best, xy) {
+			best = p
+		}
+	}
+
+	return best
+}
+
+// ClampKelvins returns colour temperature limited to the range supported by
+// the gamut.
+func (g Gamut) ClampKelvins(k uint16) uint16 {
+	if g.MinKelvins != 0 && k < g.MinKelvins {
+		return g.MinKelvins
+	}
+
+	if g.MaxKelvins != 0 && k > g.MaxKelvins {
+		return g.MaxKelvins
+	}
+
+	return k
+}
@@ -0,0 +1,24 @@
+package helvargo
+
+import (
+	"fmt"
+
+	"github.com/nuqz/helvar-go/message"
+)
+
+// DesyncError is returned when a reply read from a connection doesn't belong
+// to the request sent over it. The connection is reset when this happens,
+// because every following reply on it would be shifted as well.
+type DesyncError struct {
+	Request *message.Message
+	Reply   *message.Message
+}
+
+func (e *DesyncError) Error() string {
+	return fmt.Sprintf("connection is out of sync: sent %s, received %s",
+		e.Request, e.Reply)
+}
+
+// ReplyError is returned when a router replies with an error message, see
+// message.ReplyError.
+type ReplyError = message.ReplyError
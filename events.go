@@ -0,0 +1,127 @@
+package helvargo
+
+import (
+	"github.com/nuqz/helvar-go/message"
+	"golang.org/x/exp/slices"
+)
+
+// Event is a change in a HelvarNET network, which wasn't requested by this
+// client, e.g. a scene recalled from a panel or by Designer.
+type Event interface {
+	// Message returns the unsolicited message an event was made of.
+	Message() *message.Message
+	// Group returns ID of a group an event relates to or 0.
+	Group() uint16
+	// Address returns address of a device an event relates to or "".
+	Address() string
+}
+
+type baseEvent struct {
+	msg *message.Message
+}
+
+func (e baseEvent) Message() *message.Message { return e.msg }
+func (e baseEvent) Group() uint16             { return e.msg.GetGroupID() }
+func (e baseEvent) Address() string           { return e.msg.GetAddress() }
+
+// SceneRecalled is emitted when a scene is recalled in a group or on a
+// device.
+type SceneRecalled struct {
+	baseEvent
+
+	Block, Scene uint8
+}
+
+// LevelChanged is emitted when a level is set directly on a group or on a
+// device.
+type LevelChanged struct {
+	baseEvent
+
+	Level uint8
+}
+
+// ColorChanged is emitted when a colour is set directly on a group or on a
+// device. X and Y are CIE 1931 chromaticity coordinates.
+type ColorChanged struct {
+	baseEvent
+
+	Level uint8
+	X, Y  float64
+}
+
+// ColorTemperatureChanged is emitted when a colour temperature is set
+// directly on a group or on a device.
+type ColorTemperatureChanged struct {
+	baseEvent
+
+	Level  uint8
+	Mireds uint16
+}
+
+// UnknownEvent is emitted for unsolicited messages, which can't be
+// represented by any other event.
+type UnknownEvent struct {
+	baseEvent
+}
+
+func paramUint8(msg *message.Message, id message.ParameterID) uint8 {
+	v, _ := msg.GetUint(id)
+	return uint8(v)
+}
+
+// NewEvent returns an event made of an unsolicited message.
+func NewEvent(msg *message.Message) Event {
+	base := baseEvent{msg}
+
+	switch msg.GetCommandID() {
+	case message.RecallSceneGroup, message.RecallSceneDevice:
+		return SceneRecalled{
+			baseEvent: base,
+			Block:     paramUint8(msg, message.Block),
+			Scene:     paramUint8(msg, message.Scene),
+		}
+	case message.DirectLevelGroup, message.DirectLevelDevice:
+		level := paramUint8(msg, message.Level)
+		if x, ok := msg.GetFloat(message.ColourX); ok {
+			y, _ := msg.GetFloat(message.ColourY)
+			return ColorChanged{baseEvent: base, Level: level, X: x, Y: y}
+		}
+
+		if m, ok := msg.GetUint(message.Mireds); ok {
+			return ColorTemperatureChanged{
+				baseEvent: base,
+				Level:     level,
+				Mireds:    uint16(m),
+			}
+		}
+
+		return LevelChanged{baseEvent: base, Level: level}
+	}
+
+	return UnknownEvent{base}
+}
+
+// EventFilter decides whether an event should be delivered to a subscriber.
+// A nil filter accepts all events.
+type EventFilter func(Event) bool
+
+// GroupEvents returns a filter accepting events related to given groups.
+func GroupEvents(ids ...uint16) EventFilter {
+	return func(e Event) bool {
+		return e.Group() != 0 && slices.Contains(ids, e.Group())
+	}
+}
+
+// DeviceEvents returns a filter accepting events related to given devices.
+func DeviceEvents(addrs ...string) EventFilter {
+	return func(e Event) bool {
+		return e.Address() != "" && slices.Contains(addrs, e.Address())
+	}
+}
+
+// CommandEvents returns a filter accepting events made of given commands.
+func CommandEvents(ids ...message.CommandID) EventFilter {
+	return func(e Event) bool {
+		return slices.Contains(ids, e.Message().GetCommandID())
+	}
+}
@@ -0,0 +1,152 @@
+package helvargo
+
+import (
+	"context"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+)
+
+// Ping is an outcome of a QueryTime exchange with a router.
+type Ping struct {
+	// At is the moment the reply was received.
+	At time.Time
+	// RTT is the round-trip time of the exchange.
+	RTT time.Duration
+	// Skew is the difference between the router clock and the local one,
+	// positive when the router is ahead. Routers report time in seconds,
+	// so it is precise to a second only.
+	Skew time.Duration
+}
+
+// newPing returns a ping made of a reply to QueryTime sent at start and
+// received at end.
+func newPing(reply *message.Message, start, end time.Time) (Ping, error) {
+	ts, err := reply.AnswerInt()
+	if err != nil {
+		return Ping{}, errors.Wrap(err, "failed to query network time")
+	}
+
+	rtt := end.Sub(start)
+	// The router has most likely answered halfway.
+	local := start.Add(rtt / 2).Truncate(time.Second)
+	return Ping{At: end, RTT: rtt, Skew: time.Unix(ts, 0).Sub(local)}, nil
+}
+
+// Ping sends QueryTime to the router right away and returns the round-trip
+// time and the skew of the router clock. Unlike regular calls, it bypasses
+// interceptors, the rate limiter, the circuit breaker and retries, so it
+// reflects reachability of the router as is.
+func (c *Client) Ping(ctx context.Context) (Ping, error) {
+	ctx, err := c.acquire(ctx)
+	if err != nil {
+		return Ping{}, errors.Wrapf(err, "failed to ping %s", c.address)
+	}
+	defer c.release()
+
+	start := time.Now()
+	reply, err := c.attempt(WithPriority(ctx, PriorityHigh), 0,
+		message.NewQueryTime())
+	if err != nil {
+		return Ping{}, errors.Wrapf(err, "failed to ping %s", c.address)
+	}
+
+	return newPing(reply, start, time.Now())
+}
+
+// ConnectionHealth is a state of a connection of a transceiver.
+type ConnectionHealth struct {
+	// Alive is true when the transceiver is running.
+	Alive bool
+	// Connected is true when the connection is established. It is dropped
+	// after failures and re-established by the next request.
+	Connected bool
+
+	// LastSuccess is the moment of the last successful exchange.
+	LastSuccess time.Time
+	// LastError is the last transport error along with its moment, errors
+	// of cancelled calls are not counted.
+	LastError   error
+	LastErrorAt time.Time
+	// KeepAlive is the outcome of the last successful keep alive probe,
+	// it is zero until the first one.
+	KeepAlive Ping
+
+	Reconnects        int64
+	KeepAliveFailures int64
+}
+
+// record accounts an outcome of an exchange in the status.
+func (t *Transceiver) record(ctx context.Context, err error) {
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	t.statusMu.Lock()
+	defer t.statusMu.Unlock()
+
+	if err != nil {
+		t.status.LastError, t.status.LastErrorAt = err, time.Now()
+		return
+	}
+	t.status.LastSuccess = time.Now()
+}
+
+// Health returns a state of the connection of the transceiver.
+func (t *Transceiver) Health() ConnectionHealth {
+	t.statusMu.Lock()
+	out := t.status
+	t.statusMu.Unlock()
+
+	// The connection may be being used, its state is tracked separately,
+	// so health checks don't wait for exchanges in flight.
+	out.Connected = t.connected.Load()
+
+	out.Alive = t.IsAlive()
+	out.Reconnects = t.Reconnects()
+	out.KeepAliveFailures = t.KeepAliveFailures()
+	return out
+}
+
+// Health is a snapshot of the state of a client.
+type Health struct {
+	Connected   bool
+	Breaker     BreakerState
+	Connections []ConnectionHealth
+}
+
+// Ready returns true when the client is connected, its circuit breaker is
+// closed and at least one of its transceivers is running.
+func (h Health) Ready() bool {
+	if !h.Connected || h.Breaker != BreakerClosed {
+		return false
+	}
+
+	for _, conn := range h.Connections {
+		if conn.Alive {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Health returns the current state of the client and its connections. It
+// doesn't send anything, see Ping for that.
+func (c *Client) Health() Health {
+	c.mu.RLock()
+	out := Health{
+		Connected:   c.state == stateConnected,
+		Connections: make([]ConnectionHealth, len(c.transceivers)),
+	}
+	transceivers := c.transceivers
+	c.mu.RUnlock()
+
+	out.Breaker = c.breaker.Load().current()
+	for i, t := range transceivers {
+		out.Connections[i] = t.Health()
+	}
+
+	return out
+}
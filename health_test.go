@@ -0,0 +1,123 @@
+package helvargo
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPing(t *testing.T) {
+	start := time.Unix(1000, 0)
+	for tcDescription, tc := range map[string]struct {
+		answer string
+		end    time.Time
+		rtt    time.Duration
+		skew   time.Duration
+		err    bool
+	}{
+		"in sync": {
+			answer: "1000", end: start.Add(10 * time.Millisecond),
+			rtt: 10 * time.Millisecond,
+		},
+		"router ahead": {
+			answer: "1030", end: start.Add(time.Second),
+			rtt: time.Second, skew: 30 * time.Second,
+		},
+		"router behind": {
+			answer: "990", end: start.Add(4 * time.Second),
+			rtt: 4 * time.Second, skew: -12 * time.Second,
+		},
+		"malformed": {answer: "now", end: start, err: true},
+	} {
+		t.Run(tcDescription, func(t *testing.T) {
+			reply := &message.Message{Type: message.TReply, Answer: tc.answer}
+			ping, err := newPing(reply, start, tc.end)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.end, ping.At)
+			assert.Equal(t, tc.rtt, ping.RTT)
+			assert.Equal(t, tc.skew, ping.Skew)
+		})
+	}
+}
+
+func TestHealth(t *testing.T) {
+	var down atomic.Bool
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		if down.Load() {
+			return ""
+		}
+		return (&message.Message{
+			Type:       message.TReply,
+			Parameters: req.Parameters,
+			Answer:     strconv.FormatInt(time.Now().Unix()+60, 10),
+		}).String()
+	})
+	c.SetRetryPolicy(NoRetries)
+
+	h := c.Health()
+	assert.True(t, h.Ready())
+	require.Len(t, h.Connections, 1)
+	assert.True(t, h.Connections[0].LastSuccess.IsZero())
+
+	ping, err := c.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Positive(t, ping.RTT)
+	assert.InDelta(t, time.Minute, ping.Skew, float64(2*time.Second))
+
+	h = c.Health()
+	assert.True(t, h.Connections[0].Connected)
+	assert.False(t, h.Connections[0].LastSuccess.IsZero())
+	assert.NoError(t, h.Connections[0].LastError)
+
+	down.Store(true)
+	_, err = c.Ping(context.Background())
+	require.Error(t, err)
+
+	h = c.Health()
+	assert.True(t, h.Ready())
+	assert.Error(t, h.Connections[0].LastError)
+	assert.False(t, h.Connections[0].Connected)
+
+	c.Disconnect()
+	h = c.Health()
+	assert.False(t, h.Connected)
+	assert.False(t, h.Ready())
+}
+
+func TestHealthDoesntWaitForCalls(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	received := make(chan struct{}, 1)
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		<-block
+		return ""
+	}, WithPoolSize(1))
+
+	go func() { _, _ = c.GetGroups() }()
+	<-received
+
+	done := make(chan Health)
+	go func() { done <- c.Health() }()
+	select {
+	case h := <-done:
+		assert.True(t, h.Connections[0].Connected)
+	case <-time.After(time.Second):
+		t.Fatal("health check waits for a call in flight")
+	}
+}
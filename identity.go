@@ -0,0 +1,131 @@
+package helvargo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+)
+
+// RouterID is the identity of a router in a workgroup, routers are
+// addressed by it as @cluster.router.
+type RouterID struct {
+	Cluster uint8
+	Router  uint8
+}
+
+func (id RouterID) String() string {
+	return fmt.Sprintf("%d.%d", id.Cluster, id.Router)
+}
+
+// complete returns true when both parts of the identity are known.
+func (id RouterID) complete() bool { return id.Cluster != 0 && id.Router != 0 }
+
+// matches returns true when a given identity matches this one, zero parts
+// match anything.
+func (id RouterID) matches(other RouterID) bool {
+	return (id.Cluster == 0 || id.Cluster == other.Cluster) &&
+		(id.Router == 0 || id.Router == other.Router)
+}
+
+// WithRouterID sets the identity of the router. The router isn't asked,
+// when both of the IDs are set. Otherwise the missing one is looked up by
+// Identify, e.g. RouterID{Router: 252} is enough, unless there are routers
+// with ID 252 in several clusters.
+func WithRouterID(id RouterID) Option {
+	return func(c *Client) {
+		if id.complete() {
+			c.routerID.Store(&id)
+			return
+		}
+		c.routerHint = id
+	}
+}
+
+// hasRouterID returns true, when the identity of the router is known or
+// given partially by WithRouterID.
+func (c *Client) hasRouterID() bool {
+	return c.routerID.Load() != nil || c.routerHint != RouterID{}
+}
+
+// routerKey returns the cluster.router identity of the router the client
+// communicates to, when it is known: it is set explicitly or identified
+// already.
+func (c *Client) routerKey() (string, bool) {
+	if id := c.routerID.Load(); id != nil {
+		return id.String(), true
+	}
+
+	return "", false
+}
+
+// Identify returns the identity of the router the client communicates to,
+// unless it is set by WithRouterID, it is identified once per client.
+// HelvarNET has no query of a router's own identity, so the router is asked
+// for routers of all clusters of its workgroup, the one matching the
+// partial identity given by WithRouterID is the router itself. Without
+// WithRouterID only a router, which is alone in its workgroup, can be
+// identified.
+func (c *Client) Identify(ctx context.Context) (RouterID, error) {
+	if id := c.routerID.Load(); id != nil {
+		return *id, nil
+	}
+
+	candidates, err := c.lookupRouters(ctx, c.routerHint)
+	if err != nil {
+		return RouterID{}, errors.Wrapf(err, "failed to identify %s",
+			c.address)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return RouterID{}, errors.Errorf("%s doesn't know router %s",
+			c.address, c.routerHint)
+	case 1:
+	default:
+		return RouterID{}, errors.Errorf(
+			"%s may be any of %d routers, set its ID with WithRouterID",
+			c.address, len(candidates))
+	}
+
+	id := candidates[0]
+	c.routerID.CompareAndSwap(nil, &id)
+	c.log.Info("router identified", "id", id)
+	return id, nil
+}
+
+// lookupRouters returns identities of routers of the workgroup, which match
+// a given partial identity.
+func (c *Client) lookupRouters(
+	ctx context.Context,
+	match RouterID,
+) ([]RouterID, error) {
+	clusterIDs, err := c.queryIDsContext(ctx, message.NewQueryClusters())
+	if err != nil {
+		return nil, err
+	}
+
+	out := []RouterID{}
+	for _, cluster := range clusterIDs {
+		if match.Cluster != 0 && int(match.Cluster) != cluster {
+			continue
+		}
+
+		routerIDs, err := c.queryIDsContext(ctx,
+			message.NewQueryRouters(strconv.Itoa(cluster)))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, router := range routerIDs {
+			id := RouterID{Cluster: uint8(cluster), Router: uint8(router)}
+			if match.matches(id) {
+				out = append(out, id)
+			}
+		}
+	}
+
+	return out, nil
+}
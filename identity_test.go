@@ -0,0 +1,89 @@
+package helvargo
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/nuqz/helvar-go/members"
+	ht "github.com/nuqz/helvar-go/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentify(t *testing.T) {
+	router := ht.NewRouter("", ht.MustNetFromYAMLFile(
+		path.Join("testing", "test_net.yml")))
+	single := ht.NewRouter("", ht.Network{
+		Clusters: []members.Cluster{{ID: 4}},
+		Routers:  []members.Router{{ID: 254, Cluster: 4}},
+	})
+
+	for tcDescription, tc := range map[string]struct {
+		addr     string
+		opts     []Option
+		expected RouterID
+		err      bool
+	}{
+		"router ID": {
+			addr: "router.example.com",
+			opts: []Option{
+				WithConnFactory(router.Dial),
+				WithRouterID(RouterID{Router: 252}),
+			},
+			expected: RouterID{Cluster: 2, Router: 252},
+		},
+		"cluster ID": {
+			addr: "router.example.com",
+			opts: []Option{
+				WithConnFactory(router.Dial),
+				WithRouterID(RouterID{Cluster: 3}),
+			},
+			expected: RouterID{Cluster: 3, Router: 253},
+		},
+		"alone in workgroup": {
+			addr:     "router.example.com",
+			opts:     []Option{WithConnFactory(single.Dial)},
+			expected: RouterID{Cluster: 4, Router: 254},
+		},
+		"explicit": {
+			addr: "router.example.com",
+			opts: []Option{
+				WithConnFactory(router.Dial),
+				WithRouterID(RouterID{Cluster: 7, Router: 7}),
+			},
+			expected: RouterID{Cluster: 7, Router: 7},
+		},
+		"unknown to the router": {
+			addr: "router.example.com",
+			opts: []Option{
+				WithConnFactory(router.Dial),
+				WithRouterID(RouterID{Router: 200}),
+			},
+			err: true,
+		},
+		"address is not parsed": {
+			addr: "10.254.1.251",
+			opts: []Option{WithConnFactory(router.Dial)},
+			err:  true,
+		},
+	} {
+		t.Run(tcDescription, func(t *testing.T) {
+			c := NewClient(tc.addr, tc.opts...)
+			_, err := c.Connect()
+			require.NoError(t, err)
+			defer c.Disconnect()
+
+			id, err := c.Identify(context.Background())
+			if tc.err {
+				assert.Error(t, err)
+				assert.False(t, c.IsSameSubnet("1.251.1"))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, id)
+			assert.True(t, c.IsSameSubnet(id.String()+".1"))
+		})
+	}
+}
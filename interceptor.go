@@ -0,0 +1,139 @@
+package helvargo
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+)
+
+// Invoker sends a message and returns its reply, if the message is answered.
+type Invoker func(
+	ctx context.Context,
+	msg *message.Message,
+) (*message.Message, error)
+
+// Interceptor is called for every message sent by a client. It may inspect
+// or modify a message before passing it to next, inspect or modify a reply
+// and an error returned by next, time the call or short-circuit it by not
+// calling next at all. A message may be pooled and reused once the call
+// returns (see message.Acquire), so an interceptor must not keep it or its
+// parameters after that, but a copy of them. Interceptors, which keep
+// sending a message after a cancelled call returns, must document it, see
+// CoalescingInterceptor.
+type Interceptor func(
+	ctx context.Context,
+	msg *message.Message,
+	next Invoker,
+) (*message.Message, error)
+
+// chain returns an invoker, which calls interceptors in a given order before
+// the invoker itself.
+func chain(invoker Invoker, interceptors ...Interceptor) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(
+			ctx context.Context,
+			msg *message.Message,
+		) (*message.Message, error) {
+			return interceptor(ctx, msg, next)
+		}
+	}
+
+	return invoker
+}
+
+// LoggingInterceptor returns an interceptor, which logs every message sent
+// with its command, reply or error and the duration of the call with a given
+// logger. Failed calls are logged as warnings, others at debug level, nil
+// logger discards records.
+func LoggingInterceptor(l *slog.Logger) Interceptor {
+	if l == nil {
+		l = discardLogger
+	}
+
+	return func(
+		ctx context.Context,
+		msg *message.Message,
+		next Invoker,
+	) (*message.Message, error) {
+		start := time.Now()
+		reply, err := next(ctx, msg)
+		took := time.Since(start)
+
+		switch {
+		case err != nil:
+			l.WarnContext(ctx, "call failed", "command", msg.GetCommandID(),
+				"message", msg, "error", err, "took", took)
+		case reply != nil:
+			l.DebugContext(ctx, "call replied", "command", msg.GetCommandID(),
+				"message", msg, "reply", reply, "took", took)
+		default:
+			l.DebugContext(ctx, "call sent", "command", msg.GetCommandID(),
+				"message", msg, "took", took)
+		}
+
+		return reply, err
+	}
+}
+
+// Metrics are basic counters of messages sent by a client, see
+// MetricsInterceptor. It is safe for concurrent use.
+type Metrics struct {
+	requests        atomic.Int64
+	transportErrors atomic.Int64
+	replyErrors     atomic.Int64
+	latency         atomic.Int64
+}
+
+// MetricsSnapshot is a state of Metrics at some moment.
+type MetricsSnapshot struct {
+	// Requests is the number of calls, including failed ones.
+	Requests int64
+	// TransportErrors is the number of calls failed for any reason but an
+	// error reply from a router.
+	TransportErrors int64
+	// ReplyErrors is the number of calls answered with an error by a
+	// router.
+	ReplyErrors int64
+	// TotalLatency is the sum of durations of all calls.
+	TotalLatency time.Duration
+}
+
+// Snapshot returns current values of metrics.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	return MetricsSnapshot{
+		Requests:        m.requests.Load(),
+		TransportErrors: m.transportErrors.Load(),
+		ReplyErrors:     m.replyErrors.Load(),
+		TotalLatency:    time.Duration(m.latency.Load()),
+	}
+}
+
+// MetricsInterceptor returns an interceptor, which counts calls, their
+// outcomes and latency in m.
+func MetricsInterceptor(m *Metrics) Interceptor {
+	return func(
+		ctx context.Context,
+		msg *message.Message,
+		next Invoker,
+	) (*message.Message, error) {
+		start := time.Now()
+		reply, err := next(ctx, msg)
+
+		m.requests.Add(1)
+		m.latency.Add(int64(time.Since(start)))
+
+		var replyErr *ReplyError
+		if errors.As(err, &replyErr) {
+			m.replyErrors.Add(1)
+		} else if err != nil {
+			m.transportErrors.Add(1)
+		}
+
+		return reply, err
+	}
+}
@@ -0,0 +1,119 @@
+package helvargo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/nuqz/helvar-go/members"
+	"github.com/nuqz/helvar-go/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptorsOrderAndShortCircuit(t *testing.T) {
+	calls := []string{}
+	trace := func(name string) Interceptor {
+		return func(
+			ctx context.Context,
+			msg *message.Message,
+			next Invoker,
+		) (*message.Message, error) {
+			calls = append(calls, name+" in")
+			reply, err := next(ctx, msg)
+			calls = append(calls, name+" out")
+			return reply, err
+		}
+	}
+
+	// The client is not connected, so the call succeeds only if it is
+	// short-circuited.
+	c := NewClient("localhost")
+	c.Use(trace("outer"), trace("inner"))
+	c.Use(func(
+		ctx context.Context,
+		msg *message.Message,
+		next Invoker,
+	) (*message.Message, error) {
+		calls = append(calls, "cache")
+		return &message.Message{Type: message.TReply, Answer: "Cached"}, nil
+	})
+
+	name, err := c.GetGroupName(members.Group{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "Cached", name)
+	assert.Equal(t,
+		[]string{"outer in", "inner in", "cache", "inner out", "outer out"},
+		calls)
+}
+
+func TestInterceptorModifiesMessage(t *testing.T) {
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		return (&message.Message{
+			Type:       message.TReply,
+			Parameters: req.Parameters,
+			Answer:     fmt.Sprintf("Group %d", req.GetGroupID()),
+		}).String()
+	})
+
+	var logged bytes.Buffer
+	c.Use(LoggingInterceptor(slog.New(slog.NewTextHandler(&logged,
+		&slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+	m := &Metrics{}
+	c.Use(MetricsInterceptor(m))
+
+	c.Use(func(
+		ctx context.Context,
+		msg *message.Message,
+		next Invoker,
+	) (*message.Message, error) {
+		if msg.GetCommandID() == message.QueryGroupDescription {
+			msg = message.NewQueryGroupDescription(msg.GetGroupID() + 1)
+		}
+		return next(ctx, msg)
+	})
+
+	name, err := c.GetGroupName(members.Group{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "Group 2", name)
+
+	_, err = c.Command(message.QueryGroup)
+	assert.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(logged.String()), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "level=DEBUG")
+	assert.Contains(t, lines[0], "command=QueryGroupDescription")
+	assert.Contains(t, lines[0], "reply=")
+	assert.Contains(t, lines[0], "took=")
+
+	snapshot := m.Snapshot()
+	assert.Equal(t, int64(1), snapshot.Requests)
+	assert.Zero(t, snapshot.TransportErrors)
+	assert.Positive(t, snapshot.TotalLatency)
+}
+
+func TestLoggingInterceptorWarnsOnError(t *testing.T) {
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		return ""
+	})
+	c.SetRetryPolicy(NoRetries)
+
+	var logged bytes.Buffer
+	c.Use(LoggingInterceptor(slog.New(slog.NewTextHandler(&logged,
+		&slog.HandlerOptions{Level: slog.LevelWarn}))))
+
+	_, err := c.GetGroups()
+	require.Error(t, err)
+	assert.Contains(t, logged.String(), "level=WARN")
+	assert.Contains(t, logged.String(), "command=QueryGroups")
+	assert.Contains(t, logged.String(), "error=")
+
+	c.Use(LoggingInterceptor(nil))
+	_, err = c.GetGroups()
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package helvargo
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+)
+
+const (
+	// EventBufferSize is a capacity of channels returned by
+	// Client.Subscribe. Events are dropped for subscribers, which don't keep
+	// up with them.
+	EventBufferSize = 64
+
+	// ListenerRetryDuration is a delay before the listener connection is
+	// re-established after a failure.
+	ListenerRetryDuration = 5 * time.Second
+)
+
+type subscription struct {
+	filter EventFilter
+	out    chan Event
+}
+
+// listener reads unsolicited messages from a dedicated connection, which is
+// never used for requests, and fans them out to subscribers as events.
+type listener struct {
+	dial DialFunc
+	log  *slog.Logger
+	done chan struct{}
+
+	mu   sync.Mutex
+	conn net.Conn
+	subs map[*subscription]struct{}
+}
+
+func newListener(dial DialFunc, log *slog.Logger) (*listener, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &listener{
+		dial: dial,
+		log:  log,
+		done: make(chan struct{}),
+		conn: conn,
+		subs: map[*subscription]struct{}{},
+	}
+	go l.run(conn)
+
+	return l, nil
+}
+
+func (l *listener) run(conn net.Conn) {
+	defer func() {
+		l.mu.Lock()
+		for sub := range l.subs {
+			close(sub.out)
+			delete(l.subs, sub)
+		}
+		l.mu.Unlock()
+	}()
+
+	for {
+		if conn != nil {
+			err := l.read(conn)
+			select {
+			case <-l.done:
+				return
+			default:
+				l.log.Warn("connection lost", "error", err)
+			}
+		}
+
+		select {
+		case <-l.done:
+			return
+		case <-time.After(ListenerRetryDuration):
+		}
+
+		var err error
+		if conn, err = l.dial(); err != nil {
+			l.log.Warn("failed to reconnect", "error", err)
+			conn = nil
+			continue
+		}
+		l.log.Info("reconnected")
+
+		l.mu.Lock()
+		select {
+		case <-l.done:
+			l.mu.Unlock()
+			// The listener is closed anyway, nothing to do with an error.
+			_ = conn.Close()
+			return
+		default:
+			l.conn = conn
+		}
+		l.mu.Unlock()
+	}
+}
+
+// read publishes events read from a connection until it fails.
+func (l *listener) read(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	for {
+		raw, err := r.ReadString(terminatorByte)
+		if err != nil {
+			return err
+		}
+
+		msg, err := message.Parse(raw)
+		if err != nil || !msg.IsUnsolicited() {
+			continue
+		}
+
+		l.publish(NewEvent(msg))
+	}
+}
+
+func (l *listener) publish(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for sub := range l.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+
+		select {
+		case sub.out <- e:
+		default:
+		}
+	}
+}
+
+func (l *listener) subscribe(filter EventFilter) (<-chan Event, func()) {
+	sub := &subscription{
+		filter: filter,
+		out:    make(chan Event, EventBufferSize),
+	}
+
+	l.mu.Lock()
+	l.subs[sub] = struct{}{}
+	l.mu.Unlock()
+
+	return sub.out, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if _, ok := l.subs[sub]; ok {
+			close(sub.out)
+			delete(l.subs, sub)
+		}
+	}
+}
+
+func (l *listener) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	close(l.done)
+	if l.conn != nil {
+		return l.conn.Close()
+	}
+
+	return nil
+}
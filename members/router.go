@@ -4,4 +4,8 @@ type Router struct {
 	// ID is uint8, however HelvarNET protocol assumes it is within
 	// [1..254] range.
 	ID uint8
+
+	// Cluster is the ID of the cluster of the router, it is zero when
+	// unknown.
+	Cluster uint8
 }
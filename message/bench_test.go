@@ -0,0 +1,145 @@
+package message
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/nuqz/helvar-go/colour"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	benchXY   = colour.XY{X: 0.3127, Y: 0.329}
+	benchAddr = "1.2.3.4"
+)
+
+func TestBuildersMatchConstructors(t *testing.T) {
+	msg := &Message{}
+	testCases := map[string]struct{ built, expected *Message }{
+		"direct level group": {
+			msg.SetDirectLevelGroup(1000, 50, Parameter{FadeTime, 100}),
+			NewDirectLevelGroup(1000, 50, Parameter{FadeTime, 100}),
+		},
+		"direct level device": {
+			(&Message{}).SetDirectLevelDevice(benchAddr, 50),
+			NewDirectLevelDevice(benchAddr, 50),
+		},
+		"scene recall group": {
+			(&Message{}).SetRecallSceneGroup(1000, 1, 16),
+			NewRecallSceneGroup(1000, 1, 16),
+		},
+		"scene recall device": {
+			(&Message{}).SetRecallSceneDevice(benchAddr, 2, 3,
+				Parameter{FadeTime, 100}),
+			NewRecallSceneDevice(benchAddr, 2, 3, Parameter{FadeTime, 100}),
+		},
+		"colour temperature group": {
+			(&Message{}).SetColorTemperatureGroup(1000, 2700, 50),
+			NewColorTemperatureGroup(1000, 2700, 50),
+		},
+		"colour temperature device": {
+			(&Message{}).SetColorTemperatureDevice(benchAddr, 6500, 50),
+			NewColorTemperatureDevice(benchAddr, 6500, 50),
+		},
+		"colour group": {
+			(&Message{}).SetColorGroup(1000, colour.FromRGB(255, 0, 0), 50),
+			NewColorGroup(1000, color.RGBA{255, 0, 0, 255}, 50),
+		},
+		"rgb device": {
+			(&Message{}).SetRGBDevice(benchAddr, 0, 255, 0, 50),
+			NewRGBDevice(benchAddr, 0, 255, 0, 50),
+		},
+	}
+
+	for tcDescription, tc := range testCases {
+		t.Run(tcDescription, func(t *testing.T) {
+			assert.Equal(t, tc.expected.String(), tc.built.String())
+		})
+	}
+}
+
+func TestBuildersDontAllocate(t *testing.T) {
+	msg := &Message{}
+	buf := make([]byte, 0, 128)
+
+	// Warm up interned values.
+	msg.SetDirectLevelDevice(benchAddr, 1)
+	msg.SetDirectLevelGroup(1000, 1)
+
+	testCases := map[string]func(){
+		"direct level group": func() {
+			buf = msg.SetDirectLevelGroup(1000, 50).AppendTo(buf[:0])
+		},
+		"direct level device": func() {
+			buf = msg.SetDirectLevelDevice(benchAddr, 50).AppendTo(buf[:0])
+		},
+		"colour group": func() {
+			buf = msg.SetColorGroup(1000, benchXY, 50).AppendTo(buf[:0])
+		},
+		"rgb device": func() {
+			buf = msg.SetRGBDevice(benchAddr, 10, 20, 30, 50).
+				AppendTo(buf[:0])
+		},
+		"validate": func() {
+			_ = Validate(msg.SetColorGroup(1000, benchXY, 50))
+		},
+	}
+
+	for tcDescription, f := range testCases {
+		t.Run(tcDescription, func(t *testing.T) {
+			assert.Zero(t, testing.AllocsPerRun(100, f))
+		})
+	}
+}
+
+func BenchmarkNewColorGroup(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = NewColorGroup(1000, benchXY, 50).Bytes()
+	}
+}
+
+func BenchmarkNewDirectLevelGroup(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = NewDirectLevelGroup(1000, 50).Bytes()
+	}
+}
+
+func BenchmarkPooledColorGroup(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 128)
+	for i := 0; i < b.N; i++ {
+		msg := Acquire().SetColorGroup(1000, benchXY, 50)
+		buf = msg.AppendTo(buf[:0])
+		Release(msg)
+	}
+}
+
+func BenchmarkPooledDirectLevelGroup(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 128)
+	for i := 0; i < b.N; i++ {
+		msg := Acquire().SetDirectLevelGroup(1000, 50)
+		buf = msg.AppendTo(buf[:0])
+		Release(msg)
+	}
+}
+
+func BenchmarkPooledRGBDevice(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 128)
+	for i := 0; i < b.N; i++ {
+		msg := Acquire().SetRGBDevice(benchAddr, 10, 20, 30, 50)
+		buf = msg.AppendTo(buf[:0])
+		Release(msg)
+	}
+}
+
+func BenchmarkString(b *testing.B) {
+	b.ReportAllocs()
+	msg := NewColorGroup(1000, benchXY, 50)
+	for i := 0; i < b.N; i++ {
+		_ = msg.String()
+	}
+}
@@ -2,10 +2,8 @@ package message
 
 import (
 	"image/color"
-	"math"
 
-	"github.com/nuqz/col2xy"
-	"golang.org/x/exp/slices"
+	"github.com/nuqz/helvar-go/colour"
 )
 
 type CommandID uint8
@@ -43,17 +41,17 @@ const (
 	QueryHelvarnetVersion        CommandID = 191
 )
 
-var (
-	CommandsWithoutResponse = []CommandID{
-		RecallSceneGroup,
-		RecallSceneDevice,
-		DirectLevelGroup,
-		DirectLevelDevice,
-	}
-)
+// CommandsWithoutResponse lists commands, which aren't answered by a router.
+// It is filled from the registry, changing it has no effect.
+//
+// Deprecated: use LookupCommand and CommandSpec.Answered instead.
+var CommandsWithoutResponse []CommandID
 
+// NeedResponse returns true when a router is expected to reply to a given
+// message. Commands unknown to the registry are answered with an error.
 func NeedResponse(msg *Message) bool {
-	return !slices.Contains(CommandsWithoutResponse, msg.GetCommandID())
+	spec, ok := LookupCommand(msg.GetCommandID())
+	return !ok || spec.Answered()
 }
 
 func NewCommand(version uint8, id CommandID) *Message {
@@ -93,6 +91,11 @@ func NewQueryDeviceDescription(address string) *Message {
 		AddParameters(Parameter{Address, address})
 }
 
+func NewQueryDeviceState(address string) *Message {
+	return NewCommandV1(QueryDeviceState).
+		AddParameters(Parameter{Address, address})
+}
+
 func NewRecallScene(
 	cmdID CommandID,
 	block, scene uint8,
@@ -151,8 +154,7 @@ func NewDirectLevelDevice(
 }
 
 func Kelvins2Mireds(k uint16) int {
-	// The formula was taken from one of Helvar manuals
-	return int(math.Round(1000000 / float64(k)))
+	return colour.KelvinsToMireds(k)
 }
 
 func Kelvins2MiredsParam(k uint16) Parameter {
@@ -204,8 +206,8 @@ func NewColor(
 	color color.Color,
 	params ...Parameter,
 ) *Message {
-	x, y := col2xy.Color2XY(color)
-	return newColor(cmdID, level, x, y, params...)
+	xy := colour.FromColor(color)
+	return newColor(cmdID, level, xy.X, xy.Y, params...)
 }
 
 func NewColorGroup(
@@ -234,8 +236,8 @@ func NewRGB(
 	r, g, b byte,
 	params ...Parameter,
 ) *Message {
-	x, y := col2xy.RGB2XY(r, g, b)
-	return newColor(cmdID, level, x, y, params...)
+	xy := colour.FromRGB(r, g, b)
+	return newColor(cmdID, level, xy.X, xy.Y, params...)
 }
 
 func NewRGBGroup(
@@ -257,3 +259,144 @@ func NewRGBDevice(
 	return NewRGB(DirectLevelDevice, level, r, g, b, params...).
 		AddParameters(Parameter{Address, addr})
 }
+
+// The following builders reset a message and fill it with a command in
+// place. Unlike NewXxx constructors they don't allocate when used with a
+// pooled (see Acquire) or otherwise reused message. Colour coordinates are
+// rounded to two decimals, i.e. to the precision they are sent with.
+
+func (msg *Message) setCommand(version uint8, id CommandID) *Message {
+	return msg.Reset().SetType(TCommand).
+		AddParameters(
+			Parameter{Version, version},
+			Parameter{Command, id},
+		)
+}
+
+func (msg *Message) setColor(
+	cmdID CommandID,
+	xy colour.XY,
+	level uint8,
+) *Message {
+	return msg.setCommand(2, cmdID).
+		AddParameters(
+			Parameter{Level, level},
+			Parameter{ColourX, coordinateValue(xy.X)},
+			Parameter{ColourY, coordinateValue(xy.Y)})
+}
+
+// SetRecallSceneGroup makes msg a scene recall command for a group.
+func (msg *Message) SetRecallSceneGroup(
+	gid uint16,
+	block, scene uint8,
+	params ...Parameter,
+) *Message {
+	return msg.setCommand(1, RecallSceneGroup).
+		AddParameters(Parameter{Block, block}, Parameter{Scene, scene}).
+		AddParameters(params...).
+		AddParameters(Parameter{Group, groupIDValue(gid)})
+}
+
+// SetRecallSceneDevice makes msg a scene recall command for a device.
+func (msg *Message) SetRecallSceneDevice(
+	addr string,
+	block, scene uint8,
+	params ...Parameter,
+) *Message {
+	return msg.setCommand(1, RecallSceneDevice).
+		AddParameters(Parameter{Block, block}, Parameter{Scene, scene}).
+		AddParameters(params...).
+		AddParameters(Parameter{Address, addressValue(addr)})
+}
+
+// SetDirectLevelGroup makes msg a direct level command for a group.
+func (msg *Message) SetDirectLevelGroup(
+	gid uint16,
+	level uint8,
+	params ...Parameter,
+) *Message {
+	return msg.setCommand(1, DirectLevelGroup).
+		AddParameters(Parameter{Level, level}).
+		AddParameters(params...).
+		AddParameters(Parameter{Group, groupIDValue(gid)})
+}
+
+// SetDirectLevelDevice makes msg a direct level command for a device.
+func (msg *Message) SetDirectLevelDevice(
+	addr string,
+	level uint8,
+	params ...Parameter,
+) *Message {
+	return msg.setCommand(1, DirectLevelDevice).
+		AddParameters(Parameter{Level, level}).
+		AddParameters(params...).
+		AddParameters(Parameter{Address, addressValue(addr)})
+}
+
+// SetColorTemperatureGroup makes msg a colour temperature command for a
+// group.
+func (msg *Message) SetColorTemperatureGroup(
+	gid, tempK uint16,
+	level uint8,
+	params ...Parameter,
+) *Message {
+	return msg.SetDirectLevelGroup(gid, level, params...).
+		AddParameters(Kelvins2MiredsParam(tempK))
+}
+
+// SetColorTemperatureDevice makes msg a colour temperature command for a
+// device.
+func (msg *Message) SetColorTemperatureDevice(
+	addr string,
+	tempK uint16,
+	level uint8,
+	params ...Parameter,
+) *Message {
+	return msg.SetDirectLevelDevice(addr, level, params...).
+		AddParameters(Kelvins2MiredsParam(tempK))
+}
+
+// SetColorGroup makes msg a colour command for a group.
+func (msg *Message) SetColorGroup(
+	gid uint16,
+	xy colour.XY,
+	level uint8,
+	params ...Parameter,
+) *Message {
+	return msg.setColor(DirectLevelGroup, xy, level).
+		AddParameters(params...).
+		AddParameters(Parameter{Group, groupIDValue(gid)})
+}
+
+// SetColorDevice makes msg a colour command for a device.
+func (msg *Message) SetColorDevice(
+	addr string,
+	xy colour.XY,
+	level uint8,
+	params ...Parameter,
+) *Message {
+	return msg.setColor(DirectLevelDevice, xy, level).
+		AddParameters(params...).
+		AddParameters(Parameter{Address, addressValue(addr)})
+}
+
+// SetRGBGroup makes msg a colour command for a group.
+func (msg *Message) SetRGBGroup(
+	gid uint16,
+	r, g, b byte,
+	level uint8,
+	params ...Parameter,
+) *Message {
+	return msg.SetColorGroup(gid, colour.FromRGB(r, g, b), level, params...)
+}
+
+// SetRGBDevice makes msg a colour command for a device.
+func (msg *Message) SetRGBDevice(
+	addr string,
+	r, g, b byte,
+	level uint8,
+	params ...Parameter,
+) *Message {
+	return msg.SetColorDevice(addr, colour.FromRGB(r, g, b), level,
+		params...)
+}
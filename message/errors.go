@@ -1,5 +1,11 @@
 package message
 
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
 type ErrorID uint8
 
 const (
@@ -24,4 +30,74 @@ const (
 	EIncompatibleVersion    ErrorID = 18
 )
 
+var errorDescriptions = map[ErrorID]string{
+	EOK:                     "success",
+	EInvalidGroupIndex:      "invalid group index parameter",
+	EInvalidCluster:         "invalid cluster parameter",
+	EInvalidRouter:          "invalid router",
+	EInvalidSubnet:          "invalid router subnet",
+	EInvalidDevice:          "invalid device parameter",
+	EInvalidSubDevice:       "invalid sub device parameter",
+	EInvalidBlock:           "invalid block parameter",
+	EInvalidScene:           "invalid scene",
+	EClusterDoesntExist:     "cluster does not exist",
+	ERouterDoesntExist:      "router does not exist",
+	EDeviceDoesntExist:      "device does not exist",
+	EPropertyDoesntExist:    "property does not exist",
+	EInvalidRawMessageSize:  "invalid raw message size",
+	EInvalidMessagesType:    "invalid messages type",
+	EInvalidMessageCommand:  "invalid message command",
+	EMissingASCIITerminator: "missing ASCII terminator",
+	EMissingASCIIParameter:  "missing ASCII parameter",
+	EIncompatibleVersion:    "incompatible version",
+}
+
+// ErrorsByID maps error codes of HelvarNET protocol to errors.
 var ErrorsByID = map[ErrorID]error{}
+
+func init() {
+	for id := range errorDescriptions {
+		ErrorsByID[id] = id
+	}
+}
+
+// String returns a description of an error code as given in HelvarNET
+// protocol docs.
+func (id ErrorID) String() string {
+	if desc, ok := errorDescriptions[id]; ok {
+		return desc
+	}
+
+	return "unknown error " + strconv.Itoa(int(id))
+}
+
+// Error makes ErrorID usable as an error, so a code can be recovered from a
+// wrapped error with errors.As.
+func (id ErrorID) Error() string { return id.String() }
+
+// ReplyError is an error reply of a router returned as an error.
+type ReplyError struct {
+	Code  ErrorID
+	Reply *Message
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("router replied with an error: %s: %s",
+		e.Code, e.Reply)
+}
+
+// Unwrap allows to match a ReplyError against an ErrorID with errors.Is.
+func (e *ReplyError) Unwrap() error { return e.Code }
+
+// NewReplyError returns ReplyError made of an error message, its answer is
+// an error code.
+func NewReplyError(reply *Message) *ReplyError {
+	code, err := reply.AnswerInt()
+	if err != nil || code < 0 || code > math.MaxUint8 {
+		// There is no code for a malformed error reply, so it is reported
+		// as a message of invalid type.
+		code = int64(EInvalidMessagesType)
+	}
+
+	return &ReplyError{Code: ErrorID(code), Reply: reply}
+}
@@ -73,8 +73,7 @@ var (
 // as the query command message sent i.e. if a query message is sent in ASCII
 // form then the reply will also be in ASCII.
 //
-// Messages must not exceed the maximum length of 1500 bytes.
-// TODO: validate ^^^ before send.
+// Messages must not exceed the maximum length of 1500 bytes (see Validate).
 type Message struct {
 	Type       Char
 	Parameters []Parameter
@@ -93,27 +92,27 @@ func (msg *Message) SetType(t Char) *Message {
 // General format:
 // <MessageType><MessageParameter[, ...]>=<MessageResult><#|$>
 func Parse(input string) (*Message, error) {
+	if len(input) < 2 {
+		return nil, errors.Errorf("failed to parse message: %q is too short",
+			input)
+	}
+
 	start, end := Char(input[0]), Char(input[len(input)-1])
 	if !slices.Contains(allowedStartChars, start) ||
 		!slices.Contains(allowedEndChars, end) {
 		return nil, errors.Errorf("failed to parse message: %s", input)
 	}
 
-	body := strings.Trim(input, string(start)+end.String())
-	bodyParts := strings.Split(body, Answer.String())
-	result := ""
-	if len(bodyParts) == 2 {
-		result = bodyParts[1]
-	}
-
-	rawParams := strings.Split(bodyParts[0], Delimiter.String())
+	body, result, _ := strings.Cut(input[1:len(input)-1], Answer.String())
+	rawParams := strings.Split(body, Delimiter.String())
 	params := make([]Parameter, len(rawParams))
 
 	var err error
 	for i, rp := range rawParams {
 		params[i], err = ParseParameter(rp)
 		if err != nil {
-			return nil, err
+			return nil, errors.Wrapf(err, "failed to parse message: %s",
+				input)
 		}
 	}
 
@@ -150,30 +149,46 @@ func ParsePartial(in string) (*Message, error) {
 	return out, nil
 }
 
-func (msg *Message) ParamsToString() string {
-	strParams := make([]string, len(msg.Parameters))
+func (msg *Message) appendParams(dst []byte) []byte {
 	for i, param := range msg.Parameters {
-		strParams[i] = param.String()
+		if i > 0 {
+			dst = append(dst, Delimiter.Byte())
+		}
+		dst = param.AppendTo(dst)
 	}
-	return strings.Join(strParams, Delimiter.String())
+
+	return dst
+}
+
+func (msg *Message) ParamsToString() string {
+	return string(msg.appendParams(make([]byte, 0, 64)))
 }
 
 func (msg *Message) ID() string {
 	return msg.ParamsToString()
 }
 
-// String returns a Message represented as string (HelvarNET ASCII format).
-func (msg *Message) String() string {
-	msgBody := msg.ParamsToString()
+// AppendTo appends a Message represented in HelvarNET ASCII format to dst
+// and returns the extended buffer. Encoding into a reused buffer doesn't
+// allocate.
+func (msg *Message) AppendTo(dst []byte) []byte {
+	dst = append(dst, msg.Type.Byte())
+	dst = msg.appendParams(dst)
 	if msg.Answer != "" {
-		msgBody += Answer.String() + msg.Answer
+		dst = append(dst, Answer.Byte())
+		dst = append(dst, msg.Answer...)
 	}
 
-	return string(msg.Type) + msgBody + string(Terminator)
+	return append(dst, Terminator.Byte())
+}
+
+// String returns a Message represented as string (HelvarNET ASCII format).
+func (msg *Message) String() string {
+	return string(msg.AppendTo(make([]byte, 0, 64)))
 }
 
 func (msg *Message) Bytes() []byte {
-	return []byte(msg.String())
+	return msg.AppendTo(nil)
 }
 
 // AddParameters adds parameters to a given message and returns message itself
@@ -197,13 +212,57 @@ func (msg *Message) GetParameter(id ParameterID) any {
 // GetCommandID returns command ID of a given message if command parameter is
 // present, otherwise it returns 0 (NoCommand).
 func (msg *Message) GetCommandID() CommandID {
-	if v := msg.GetParameter(Command); v != nil {
-		return v.(CommandID)
+	switch v := msg.GetParameter(Command).(type) {
+	case CommandID:
+		return v
+	case uint8:
+		return CommandID(v)
 	}
 
 	return NoCommand
 }
 
+// GetUint returns parameter value as unsigned integer. The second return
+// value is false when parameter is missing or it is not a non-negative
+// integer.
+func (msg *Message) GetUint(id ParameterID) (uint64, bool) {
+	switch v := msg.GetParameter(id).(type) {
+	case uint64:
+		return v, true
+	case uint32:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint8:
+		return uint64(v), true
+	case uint:
+		return uint64(v), true
+	case int:
+		return uint64(v), v >= 0
+	case int64:
+		return uint64(v), v >= 0
+	}
+
+	return 0, false
+}
+
+// GetFloat returns parameter value as float. The second return value is
+// false when parameter is missing or it is not a number.
+func (msg *Message) GetFloat(id ParameterID) (float64, bool) {
+	switch v := msg.GetParameter(id).(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	}
+
+	if v, ok := msg.GetUint(id); ok {
+		return float64(v), true
+	}
+
+	return 0, false
+}
+
 func (msg *Message) GetGroupID() uint16 {
 	if i := msg.GetParameter(Group); i != nil {
 		switch v := i.(type) {
@@ -218,17 +277,57 @@ func (msg *Message) GetGroupID() uint16 {
 }
 
 func (msg *Message) GetAddress() string {
-	if i := msg.GetParameter(Address); i != nil {
-		return i.(string)
+	if v, ok := msg.GetParameter(Address).(string); ok {
+		return v
 	}
 
 	return ""
 }
 
+// IsUnsolicited returns true when a message was not sent as a reply, e.g. a
+// router notifies about a command issued by someone else.
+func (msg *Message) IsUnsolicited() bool {
+	return msg.Type == TCommand || msg.Type == TInternalCommand
+}
+
+// IsReplyTo returns true when a given message is a reply (or an error) to
+// the request req, i.e. its command ID and addressing parameters (group and
+// device address) match those of the request.
+func (msg *Message) IsReplyTo(req *Message) bool {
+	if msg.Type != TReply && msg.Type != TError {
+		return false
+	}
+
+	return msg.GetCommandID() == req.GetCommandID() &&
+		msg.GetGroupID() == req.GetGroupID() &&
+		msg.GetAddress() == req.GetAddress()
+}
+
 func (msg *Message) AnswerStrings() []string {
 	return strings.Split(msg.Answer, Delimiter.String())
 }
 
+// AnswerAddresses returns device addresses listed in the answer without
+// leading "@".
+func (msg *Message) AnswerAddresses() []string {
+	strs := msg.AnswerStrings()
+	for i, str := range strs {
+		strs[i] = strings.TrimPrefix(str, string(Address))
+	}
+
+	return strs
+}
+
+// AnswerInt returns the answer parsed as a single integer.
+func (msg *Message) AnswerInt() (int64, error) {
+	v, err := strconv.ParseInt(msg.Answer, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "answer %q is invalid integer", msg.Answer)
+	}
+
+	return v, nil
+}
+
 func (msg *Message) AnswerIDs() ([]int, error) {
 	strs := msg.AnswerStrings()
 
@@ -126,3 +126,62 @@ func TestMessageToString(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMalformed(t *testing.T) {
+	testCases := map[string]string{
+		"empty":                 "",
+		"terminator only":       "#",
+		"no body":               ">#",
+		"empty parameter":       ">V:1,,C:2#",
+		"negative command ID":   ">V:1,C:-1#",
+		"non-numeric command":   ">V:1,C:x#",
+		"command ID overflow":   ">V:1,C:256#",
+		"answer without params": "?=1#",
+	}
+
+	for tcDescription, input := range testCases {
+		t.Run(tcDescription, func(t *testing.T) {
+			var err error
+			assert.NotPanics(t, func() { _, err = Parse(input) })
+			assert.Error(t, err)
+		})
+	}
+}
+
+type isReplyToTestCase struct {
+	req      *Message
+	reply    string
+	expected bool
+}
+
+func TestIsReplyTo(t *testing.T) {
+	testCases := map[string]isReplyToTestCase{
+		"matching reply": {
+			NewQueryGroupDescription(1), "?V:1,C:105,G:1=Group 1#", true,
+		},
+		"matching error": {
+			NewQueryGroupDescription(1), "!V:1,C:105,G:1=1#", true,
+		},
+		"other group": {
+			NewQueryGroupDescription(1), "?V:1,C:105,G:2=Group 2#", false,
+		},
+		"other command": {
+			NewQueryGroupDescription(1), "?V:1,C:164,G:1=@1.2.3.4#", false,
+		},
+		"other device": {
+			NewQueryDeviceDescription("1.2.3.4"),
+			"?V:1,C:106,@1.2.3.5=Lamp#", false,
+		},
+		"not a reply": {
+			NewRecallSceneGroup(1, 1, 1), ">V:1,C:11,G:1,B:1,S:1#", false,
+		},
+	}
+
+	for tcDescription, tc := range testCases {
+		t.Run(tcDescription, func(t *testing.T) {
+			reply, err := Parse(tc.reply)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, reply.IsReplyTo(tc.req))
+		})
+	}
+}
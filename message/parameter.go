@@ -2,6 +2,7 @@ package message
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -45,6 +46,10 @@ type Parameter struct {
 // ParseParameter returns message parameter when input is valid string
 // representation, otherwise it returns an error.
 func ParseParameter(input string) (Parameter, error) {
+	if input == "" {
+		return Parameter{}, errors.New("empty message parameter string")
+	}
+
 	if string(input[0]) == string(Address) {
 		return Parameter{
 			ID:    Address,
@@ -74,28 +79,67 @@ func ParseParameter(input string) (Parameter, error) {
 	}
 
 	if param.ID == Command {
-		param.Value = CommandID(param.Value.(uint64))
+		v, ok := param.Value.(uint64)
+		if !ok || v > math.MaxUint8 {
+			return Parameter{}, errors.Errorf(
+				`"%s" is not valid command ID`, idValue[1])
+		}
+		param.Value = CommandID(v)
 	}
 
 	return param, nil
 }
 
-// String returns message parameter serialized in string form.
-func (p Parameter) String() string {
+// AppendTo appends message parameter serialized in string form to dst and
+// returns the extended buffer. Unlike String it doesn't allocate for values
+// of string, integer and float types.
+func (p Parameter) AppendTo(dst []byte) []byte {
+	dst = append(dst, p.ID...)
+
 	// Exception for Address parameter
 	if p.ID == Address {
-		return fmt.Sprintf("%s%s", p.ID, p.Value)
+		if v, ok := p.Value.(string); ok {
+			return append(dst, v...)
+		}
+		return fmt.Appendf(dst, "%s", p.Value)
 	}
 
-	format := "%s%s%v"
-	switch p.Value.(type) {
+	dst = append(dst, ParameterIDDelimeter.Byte())
+	switch v := p.Value.(type) {
 	case string:
-		format = "%s%s%s"
-	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
-		format = "%s%s%d"
-	case float32, float64:
-		format = "%s%s%.2f"
+		return append(dst, v...)
+	case int:
+		return strconv.AppendInt(dst, int64(v), 10)
+	case int8:
+		return strconv.AppendInt(dst, int64(v), 10)
+	case int16:
+		return strconv.AppendInt(dst, int64(v), 10)
+	case int32:
+		return strconv.AppendInt(dst, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(dst, v, 10)
+	case uint:
+		return strconv.AppendUint(dst, uint64(v), 10)
+	case uint8:
+		return strconv.AppendUint(dst, uint64(v), 10)
+	case uint16:
+		return strconv.AppendUint(dst, uint64(v), 10)
+	case uint32:
+		return strconv.AppendUint(dst, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(dst, v, 10)
+	case CommandID:
+		return strconv.AppendUint(dst, uint64(v), 10)
+	case float32:
+		return strconv.AppendFloat(dst, float64(v), 'f', 2, 32)
+	case float64:
+		return strconv.AppendFloat(dst, v, 'f', 2, 64)
 	}
 
-	return fmt.Sprintf(format, p.ID, ParameterIDDelimeter, p.Value)
+	return fmt.Appendf(dst, "%v", p.Value)
+}
+
+// String returns message parameter serialized in string form.
+func (p Parameter) String() string {
+	return string(p.AppendTo(make([]byte, 0, 16)))
 }
@@ -0,0 +1,122 @@
+package message
+
+import (
+	"math"
+	"sync"
+)
+
+// DefaultParametersCap is a capacity of parameters of pooled messages, it
+// fits any command built by this package without growing.
+const DefaultParametersCap = 8
+
+var pool = sync.Pool{
+	New: func() any {
+		return &Message{Parameters: make([]Parameter, 0, DefaultParametersCap)}
+	},
+}
+
+// Acquire returns an empty message from a pool. Together with SetXxx
+// builders and AppendTo it allows to build and encode messages without
+// allocations at high rates. A message must be given back with Release once
+// it is not used anymore, i.e. after Client.Transceive returns. Cancelled
+// and timed out calls return only after the connection is done with the
+// message as well. However, a message must not be released while a returned
+// error refers to it, nor after a cancelled call through an interceptor,
+// which keeps sending it (e.g. CoalescingInterceptor).
+func Acquire() *Message {
+	return pool.Get().(*Message)
+}
+
+// Release resets a message and puts it back to the pool. The message must
+// not be used after that.
+func Release(msg *Message) {
+	msg.Reset()
+	pool.Put(msg)
+}
+
+// Reset empties a message, but keeps the capacity of its parameters.
+func (msg *Message) Reset() *Message {
+	for i := range msg.Parameters {
+		// Drop references held by values, so they can be collected.
+		msg.Parameters[i] = Parameter{}
+	}
+
+	msg.Type = 0
+	msg.Parameters = msg.Parameters[:0]
+	msg.Answer = ""
+	msg.IsPartial = false
+	return msg
+}
+
+// Converting integers above 255, floats and strings to interface values
+// allocates. Builders use interned values instead, so they don't.
+
+// MaxGroupID is the largest group ID allowed by HelvarNET protocol.
+const MaxGroupID = 16383
+
+var (
+	groupIDsOnce sync.Once
+	groupIDs     []any
+)
+
+func groupIDValue(gid uint16) any {
+	if gid > MaxGroupID {
+		return gid
+	}
+
+	groupIDsOnce.Do(func() {
+		groupIDs = make([]any, MaxGroupID+1)
+		for i := range groupIDs {
+			groupIDs[i] = uint16(i)
+		}
+	})
+
+	return groupIDs[gid]
+}
+
+// Colour coordinates are sent with two decimals, therefore there are only
+// 101 values possible within [0..1] range.
+var coordinates = func() []any {
+	out := make([]any, 101)
+	for i := range out {
+		out[i] = float64(i) / 100
+	}
+	return out
+}()
+
+func coordinateValue(v float64) any {
+	i := math.RoundToEven(v * 100)
+	if i < 0 || i >= float64(len(coordinates)) || math.IsNaN(i) {
+		return v
+	}
+
+	return coordinates[int(i)]
+}
+
+// MaxInternedAddresses limits the number of device addresses remembered by
+// builders. Addresses beyond the limit are still accepted, but building
+// messages for them allocates.
+const MaxInternedAddresses = 4096
+
+var (
+	addressesMu sync.RWMutex
+	addresses   = map[string]any{}
+)
+
+func addressValue(addr string) any {
+	addressesMu.RLock()
+	v, ok := addresses[addr]
+	addressesMu.RUnlock()
+	if ok {
+		return v
+	}
+
+	v = addr
+	addressesMu.Lock()
+	if len(addresses) < MaxInternedAddresses {
+		addresses[addr] = v
+	}
+	addressesMu.Unlock()
+
+	return v
+}
@@ -0,0 +1,324 @@
+package message
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// Kind tells whether a command changes the state of the lighting or only
+// queries it.
+type Kind uint8
+
+const (
+	KindControl Kind = iota + 1
+	KindQuery
+)
+
+// Target tells what a command is addressed to.
+type Target uint8
+
+const (
+	TargetNone Target = iota
+	TargetGroup
+	TargetDevice
+)
+
+// ReplyType describes the payload of a reply to a command.
+type ReplyType uint8
+
+const (
+	// ReplyNone - command is not answered by a router.
+	ReplyNone ReplyType = iota
+	// ReplyIDs - comma separated list of integer IDs.
+	ReplyIDs
+	// ReplyAddresses - comma separated list of device addresses.
+	ReplyAddresses
+	// ReplyString - arbitrary text, e.g. a name or a description.
+	ReplyString
+	// ReplyInt - single integer, e.g. a level, a state or a timestamp.
+	ReplyInt
+)
+
+// CommandSpec describes a single command supported by this package.
+type CommandSpec struct {
+	ID      CommandID
+	Name    string
+	Version uint8
+	Kind    Kind
+	Target  Target
+
+	// Required parameters must be present in a command, Optional ones may
+	// be present. Version and Command parameters are implied.
+	Required []ParameterID
+	Optional []ParameterID
+
+	Reply ReplyType
+}
+
+// Answered returns true when a router replies to the command.
+func (s CommandSpec) Answered() bool { return s.Reply != ReplyNone }
+
+// VersionOf returns the version of the command with given parameters. It is
+// the version of the spec, but colour parameters require version 2.
+func (s CommandSpec) VersionOf(params []Parameter) uint8 {
+	for _, p := range params {
+		if p.ID == ColourX || p.ID == ColourY {
+			return max(s.Version, 2)
+		}
+	}
+
+	return s.Version
+}
+
+// Allows returns true when a parameter is allowed in the command.
+func (s CommandSpec) Allows(id ParameterID) bool {
+	return id == Version || id == Command ||
+		slices.Contains(s.Required, id) || slices.Contains(s.Optional, id)
+}
+
+// commands is the registry of all supported commands. Adding a command to
+// the package is a matter of adding an entry here (and a constant above).
+var commands = map[CommandID]CommandSpec{}
+
+func register(specs ...CommandSpec) {
+	for _, spec := range specs {
+		commands[spec.ID] = spec
+	}
+}
+
+func init() {
+	sceneParams := []ParameterID{FadeTime, ConstantLightScene}
+	levelParams := []ParameterID{FadeTime, Mireds, ColourX, ColourY}
+
+	register(
+		// Control
+
+		CommandSpec{
+			ID: RecallSceneGroup, Name: "RecallSceneGroup", Version: 1,
+			Kind: KindControl, Target: TargetGroup,
+			Required: []ParameterID{Group, Block, Scene},
+			Optional: sceneParams,
+		},
+		CommandSpec{
+			ID: RecallSceneDevice, Name: "RecallSceneDevice", Version: 1,
+			Kind: KindControl, Target: TargetDevice,
+			Required: []ParameterID{Address, Block, Scene},
+			Optional: sceneParams,
+		},
+		CommandSpec{
+			ID: DirectLevelGroup, Name: "DirectLevelGroup", Version: 1,
+			Kind: KindControl, Target: TargetGroup,
+			Required: []ParameterID{Group, Level},
+			Optional: levelParams,
+		},
+		CommandSpec{
+			ID: DirectLevelDevice, Name: "DirectLevelDevice", Version: 1,
+			Kind: KindControl, Target: TargetDevice,
+			Required: []ParameterID{Address, Level},
+			Optional: levelParams,
+		},
+
+		// Query
+
+		CommandSpec{
+			ID: QueryClusters, Name: "QueryClusters", Version: 1,
+			Kind: KindQuery, Reply: ReplyIDs,
+		},
+		CommandSpec{
+			ID: QueryRouters, Name: "QueryRouters", Version: 1,
+			Kind: KindQuery, Reply: ReplyIDs,
+			Required: []ParameterID{Address},
+		},
+		CommandSpec{
+			ID: QueryGroupDescription, Name: "QueryGroupDescription",
+			Version: 1, Kind: KindQuery, Target: TargetGroup,
+			Required: []ParameterID{Group},
+			Reply:    ReplyString,
+		},
+		CommandSpec{
+			ID: QueryDeviceDescription, Name: "QueryDeviceDescription",
+			Version: 1, Kind: KindQuery, Target: TargetDevice,
+			Required: []ParameterID{Address},
+			Reply:    ReplyString,
+		},
+		CommandSpec{
+			ID:   QueryDeviceTypesAndAddresses,
+			Name: "QueryDeviceTypesAndAddresses", Version: 1,
+			Kind: KindQuery, Target: TargetDevice,
+			Required: []ParameterID{Address},
+			Reply:    ReplyString,
+		},
+		CommandSpec{
+			ID: QueryDeviceState, Name: "QueryDeviceState", Version: 1,
+			Kind: KindQuery, Target: TargetDevice,
+			Required: []ParameterID{Address},
+			Reply:    ReplyInt,
+		},
+		CommandSpec{
+			ID: QueryWorkgroupName, Name: "QueryWorkgroupName", Version: 1,
+			Kind: KindQuery, Reply: ReplyString,
+		},
+		CommandSpec{
+			ID: QueryDeviceLoadLevel, Name: "QueryDeviceLoadLevel",
+			Version: 1, Kind: KindQuery, Target: TargetDevice,
+			Required: []ParameterID{Address},
+			Reply:    ReplyInt,
+		},
+		CommandSpec{
+			ID: QuerySceneInfo, Name: "QuerySceneInfo", Version: 1,
+			Kind: KindQuery, Target: TargetDevice,
+			Required: []ParameterID{Address},
+			Optional: []ParameterID{Block, Scene},
+			Reply:    ReplyString,
+		},
+		CommandSpec{
+			ID: QueryTime, Name: "QueryTime", Version: 1,
+			Kind: KindQuery, Reply: ReplyInt,
+		},
+		CommandSpec{
+			ID: QueryLastSceneInGroup, Name: "QueryLastSceneInGroup",
+			Version: 1, Kind: KindQuery, Target: TargetGroup,
+			Required: []ParameterID{Group},
+			Reply:    ReplyInt,
+		},
+		CommandSpec{
+			ID: QueryLastSceneInBlock, Name: "QueryLastSceneInBlock",
+			Version: 1, Kind: KindQuery, Target: TargetGroup,
+			Required: []ParameterID{Group, Block},
+			Reply:    ReplyInt,
+		},
+		CommandSpec{
+			ID: QueryGroup, Name: "QueryGroup", Version: 1,
+			Kind: KindQuery, Target: TargetGroup,
+			Required: []ParameterID{Group},
+			Reply:    ReplyAddresses,
+		},
+		CommandSpec{
+			ID: QueryGroups, Name: "QueryGroups", Version: 1,
+			Kind: KindQuery, Reply: ReplyIDs,
+		},
+		CommandSpec{
+			ID: QuerySceneNames, Name: "QuerySceneNames", Version: 1,
+			Kind: KindQuery, Reply: ReplyString,
+		},
+		CommandSpec{
+			ID: QueryRouterVersion, Name: "QueryRouterVersion", Version: 1,
+			Kind: KindQuery, Reply: ReplyString,
+		},
+		CommandSpec{
+			ID: QueryHelvarnetVersion, Name: "QueryHelvarnetVersion",
+			Version: 1, Kind: KindQuery, Reply: ReplyInt,
+		},
+	)
+
+	for _, spec := range Commands() {
+		if !spec.Answered() {
+			CommandsWithoutResponse = append(CommandsWithoutResponse, spec.ID)
+		}
+	}
+}
+
+// LookupCommand returns a specification of a command by its ID. The second
+// return value is false when the command is unknown to this package.
+func LookupCommand(id CommandID) (CommandSpec, bool) {
+	spec, ok := commands[id]
+	return spec, ok
+}
+
+// Commands returns specifications of all known commands ordered by ID.
+func Commands() []CommandSpec {
+	out := maps.Values(commands)
+	slices.SortFunc(out, func(a, b CommandSpec) bool { return a.ID < b.ID })
+	return out
+}
+
+// String returns the name of a command, e.g. "QueryDeviceLoadLevel" for
+// C:152. Unknown commands are represented by their numeric ID.
+func (id CommandID) String() string {
+	if spec, ok := commands[id]; ok {
+		return spec.Name
+	}
+
+	return "Command(" + strconv.Itoa(int(id)) + ")"
+}
+
+// Validate checks a given command against the registry: the command must be
+// known, all the required parameters must be present, there must be no
+// unexpected ones and the message must fit into MaxMessageBytes.
+//
+// Returned error wraps an ErrorID, which a router would reply with for the
+// same command.
+func Validate(msg *Message) error {
+	id := msg.GetCommandID()
+	spec, ok := LookupCommand(id)
+	if !ok {
+		return errors.Wrapf(EInvalidMessageCommand, "%s", msg)
+	}
+
+	for _, pid := range spec.Required {
+		if msg.GetParameter(pid) == nil {
+			return errors.Wrapf(EMissingASCIIParameter,
+				"%s requires parameter %s: %s", id, pid, msg)
+		}
+	}
+
+	for _, param := range msg.Parameters {
+		if !spec.Allows(param.ID) {
+			return errors.Wrapf(EInvalidMessageCommand,
+				"%s doesn't accept parameter %s: %s", id, param.ID, msg)
+		}
+	}
+
+	var buf [MaxMessageBytes + 1]byte
+	if n := len(msg.AppendTo(buf[:0])); n > MaxMessageBytes {
+		return errors.Wrapf(EInvalidRawMessageSize,
+			"message is %d bytes long, while %d is maximum",
+			n, MaxMessageBytes)
+	}
+
+	return nil
+}
+
+// New returns a new command with a given ID and parameters. The version of
+// the command is taken from the registry, see CommandSpec.VersionOf. An
+// error is returned when the command doesn't pass validation.
+func New(id CommandID, params ...Parameter) (*Message, error) {
+	spec, ok := LookupCommand(id)
+	if !ok {
+		return nil, errors.Wrapf(EInvalidMessageCommand,
+			"unknown command %d", id)
+	}
+
+	msg := NewCommand(spec.VersionOf(params), id).AddParameters(params...)
+	if err := Validate(msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// DecodeAnswer returns an answer of a reply decoded according to the reply
+// type of its command: []int for ReplyIDs, []string for ReplyAddresses,
+// string for ReplyString and int64 for ReplyInt.
+func (msg *Message) DecodeAnswer() (any, error) {
+	spec, ok := LookupCommand(msg.GetCommandID())
+	if !ok {
+		return nil, errors.Wrapf(EInvalidMessageCommand, "%s", msg)
+	}
+
+	switch spec.Reply {
+	case ReplyIDs:
+		return msg.AnswerIDs()
+	case ReplyAddresses:
+		return msg.AnswerAddresses(), nil
+	case ReplyString:
+		return msg.Answer, nil
+	case ReplyInt:
+		return msg.AnswerInt()
+	}
+
+	return nil, nil
+}
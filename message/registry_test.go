@@ -0,0 +1,139 @@
+package message
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/nuqz/helvar-go/colour"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandIDString(t *testing.T) {
+	assert.Equal(t, "QueryDeviceLoadLevel", QueryDeviceLoadLevel.String())
+	assert.Equal(t, "Command(250)", CommandID(250).String())
+}
+
+func TestRegistryCoversConstructors(t *testing.T) {
+	msgs := []*Message{
+		NewQueryRouters("1"),
+		NewQueryTime(),
+		NewQueryClusters(),
+		NewQueryGroups(),
+		NewQueryGroupDescription(1),
+		NewQueryGroup(1),
+		NewQueryDeviceDescription("1.2.3.4"),
+		NewQueryDeviceState("1.2.3.4"),
+		NewRecallSceneGroup(1, 1, 1),
+		NewRecallSceneDevice("1.2.3.4", 1, 1, Parameter{FadeTime, 100}),
+		NewDirectLevelGroup(1, 50),
+		NewDirectLevelDevice("1.2.3.4", 50),
+		NewColorTemperatureGroup(1, 2700, 50),
+		NewColorTemperatureDevice("1.2.3.4", 2700, 50),
+		NewColorGroup(1, color.White, 50),
+		NewRGBDevice("1.2.3.4", 255, 0, 0, 50),
+	}
+
+	for _, msg := range msgs {
+		t.Run(msg.GetCommandID().String(), func(t *testing.T) {
+			assert.NoError(t, Validate(msg), msg)
+		})
+	}
+
+	assert.False(t, NeedResponse(NewDirectLevelGroup(1, 50)))
+	assert.True(t, NeedResponse(NewQueryGroups()))
+	assert.True(t, NeedResponse(NewCommandV1(250)))
+}
+
+func TestCommandsWithoutResponse(t *testing.T) {
+	assert.ElementsMatch(t, []CommandID{
+		RecallSceneGroup,
+		RecallSceneDevice,
+		DirectLevelGroup,
+		DirectLevelDevice,
+	}, CommandsWithoutResponse)
+}
+
+func TestNew(t *testing.T) {
+	for tcDescription, tc := range map[string]struct {
+		id       CommandID
+		params   []Parameter
+		expected *Message
+	}{
+		"level": {
+			DirectLevelGroup,
+			[]Parameter{{Level, 50}, {Group, 1}},
+			NewDirectLevelGroup(1, 50),
+		},
+		"colour temperature": {
+			DirectLevelDevice,
+			[]Parameter{{Level, 50}, {Address, "1.2.3.4"}, {Mireds, 370}},
+			NewCommandV1(DirectLevelDevice).AddParameters(
+				Parameter{Level, 50},
+				Parameter{Address, "1.2.3.4"},
+				Parameter{Mireds, 370}),
+		},
+		"colour": {
+			DirectLevelGroup,
+			[]Parameter{
+				{Level, 50}, {ColourX, 0.31}, {ColourY, 0.33}, {Group, 1},
+			},
+			NewColorGroup(1, colour.XY{X: 0.31, Y: 0.33}, 50),
+		},
+	} {
+		msg, err := New(tc.id, tc.params...)
+		require.NoError(t, err, tcDescription)
+		assert.Equal(t, tc.expected.String(), msg.String(), tcDescription)
+	}
+}
+
+type validateTestCase struct {
+	msg      *Message
+	expected ErrorID
+}
+
+func TestValidate(t *testing.T) {
+	testCases := map[string]validateTestCase{
+		"unknown command": {
+			NewCommandV1(250), EInvalidMessageCommand,
+		},
+		"missing group": {
+			NewCommandV1(QueryGroup), EMissingASCIIParameter,
+		},
+		"unexpected parameter": {
+			NewQueryGroups().AddParameters(Parameter{Group, 1}),
+			EInvalidMessageCommand,
+		},
+		"too long": {
+			NewQueryDeviceDescription(strings.Repeat("1", MaxMessageBytes)),
+			EInvalidRawMessageSize,
+		},
+	}
+
+	for tcDescription, tc := range testCases {
+		t.Run(tcDescription, func(t *testing.T) {
+			err := Validate(tc.msg)
+			var code ErrorID
+			require.True(t, errors.As(err, &code), err)
+			assert.Equal(t, tc.expected, code)
+		})
+	}
+}
+
+func TestDecodeAnswer(t *testing.T) {
+	reply, err := Parse("?V:1,C:164,G:1=@1.2.3.4,@1.2.3.5#")
+	require.NoError(t, err)
+
+	v, err := reply.DecodeAnswer()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3.4", "1.2.3.5"}, v)
+
+	reply, err = Parse("?V:1,C:185=1670000000#")
+	require.NoError(t, err)
+
+	v, err = reply.DecodeAnswer()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1670000000), v)
+}
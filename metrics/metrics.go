@@ -0,0 +1,180 @@
+// Package metrics exposes Prometheus metrics of a helvargo.Client: requests
+// by command and result, their latency and the state of the client's
+// transceiver pool.
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	helvargo "github.com/nuqz/helvar-go"
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of requests, used as values of the "result" label.
+const (
+	ResultOK             = "ok"
+	ResultTransportError = "transport_error"
+	ResultReplyError     = "reply_error"
+	ResultRateLimited    = "rate_limited"
+	ResultUnavailable    = "unavailable"
+)
+
+// DefaultNamespace prefixes names of all metrics, unless a different one is
+// given to NewCollector.
+const DefaultNamespace = "helvar"
+
+// Collector is a prometheus.Collector of a single client. Register it with
+// a prometheus.Registerer, e.g. prometheus.MustRegister(collector).
+type Collector struct {
+	client *helvargo.Client
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	queueDepth        *prometheus.Desc
+	queueCapacity     *prometheus.Desc
+	transceivers      *prometheus.Desc
+	reconnects        *prometheus.Desc
+	keepAliveFailures *prometheus.Desc
+	limiterWaits      *prometheus.Desc
+	limiterWaitTime   *prometheus.Desc
+	rateLimited       *prometheus.Desc
+	breaker           *prometheus.Desc
+}
+
+// NewCollector returns a collector of metrics of a given client and installs
+// an interceptor, which measures requests, into it. Metrics are labeled
+// with the address of a router, so collectors of several clients may be
+// registered at the same registry. Empty namespace means DefaultNamespace.
+func NewCollector(client *helvargo.Client, namespace string) *Collector {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+
+	labels := prometheus.Labels{"router": client.Address()}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "client", name),
+			help, nil, labels)
+	}
+
+	c := &Collector{
+		client: client,
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "client",
+			Name:        "requests_total",
+			Help:        "Number of requests by command, result and error code.",
+			ConstLabels: labels,
+		}, []string{"command", "result", "code"}),
+
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "client",
+			Name:        "request_duration_seconds",
+			Help:        "Duration of requests, including retries.",
+			ConstLabels: labels,
+			Buckets: []float64{
+				.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5,
+			},
+		}, []string{"command"}),
+
+		queueDepth: desc("queue_depth",
+			"Number of messages waiting for a free transceiver."),
+		queueCapacity: desc("queue_capacity",
+			"Number of messages, which may wait for a free transceiver."),
+		transceivers: desc("transceivers",
+			"Number of running transceivers."),
+		reconnects: desc("reconnects_total",
+			"Number of connections re-established after a reset."),
+		keepAliveFailures: desc("keepalive_failures_total",
+			"Number of failed keep alive probes."),
+		limiterWaits: desc("limiter_waits_total",
+			"Number of requests delayed by the rate limiter."),
+		limiterWaitTime: desc("limiter_wait_seconds_total",
+			"Total time requests waited for the rate limiter."),
+		rateLimited: desc("rate_limited_total",
+			"Number of requests rejected by the rate limiter."),
+		breaker: desc("breaker_state",
+			"State of the circuit breaker: 0 closed, 1 open, 2 half-open."),
+	}
+
+	client.Use(c.intercept)
+
+	return c
+}
+
+func (c *Collector) intercept(
+	ctx context.Context,
+	msg *message.Message,
+	next helvargo.Invoker,
+) (*message.Message, error) {
+	start := time.Now()
+	reply, err := next(ctx, msg)
+
+	command := msg.GetCommandID().String()
+	c.duration.WithLabelValues(command).
+		Observe(time.Since(start).Seconds())
+
+	result, code := ResultOK, ""
+	var replyErr *helvargo.ReplyError
+	if errors.As(err, &replyErr) {
+		result = ResultReplyError
+		code = strconv.Itoa(int(replyErr.Code))
+	} else if errors.Is(err, helvargo.ErrRateLimited) {
+		result = ResultRateLimited
+	} else if errors.Is(err, helvargo.ErrRouterUnavailable) {
+		result = ResultUnavailable
+	} else if err != nil {
+		result = ResultTransportError
+	}
+	c.requests.WithLabelValues(command, result, code).Inc()
+
+	return reply, err
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.duration.Describe(ch)
+	ch <- c.queueDepth
+	ch <- c.queueCapacity
+	ch <- c.transceivers
+	ch <- c.reconnects
+	ch <- c.keepAliveFailures
+	ch <- c.limiterWaits
+	ch <- c.limiterWaitTime
+	ch <- c.rateLimited
+	ch <- c.breaker
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.duration.Collect(ch)
+
+	stats := c.client.Stats()
+	ch <- prometheus.MustNewConstMetric(c.queueDepth,
+		prometheus.GaugeValue, float64(stats.QueueDepth))
+	ch <- prometheus.MustNewConstMetric(c.queueCapacity,
+		prometheus.GaugeValue, float64(stats.QueueCapacity))
+	ch <- prometheus.MustNewConstMetric(c.transceivers,
+		prometheus.GaugeValue, float64(stats.Transceivers))
+	ch <- prometheus.MustNewConstMetric(c.reconnects,
+		prometheus.CounterValue, float64(stats.Reconnects))
+	ch <- prometheus.MustNewConstMetric(c.keepAliveFailures,
+		prometheus.CounterValue, float64(stats.KeepAliveFailures))
+	ch <- prometheus.MustNewConstMetric(c.limiterWaits,
+		prometheus.CounterValue, float64(stats.LimiterWaits))
+	ch <- prometheus.MustNewConstMetric(c.limiterWaitTime,
+		prometheus.CounterValue, stats.LimiterWaitTime.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.rateLimited,
+		prometheus.CounterValue, float64(stats.RateLimited))
+	ch <- prometheus.MustNewConstMetric(c.breaker,
+		prometheus.GaugeValue, float64(stats.Breaker))
+}
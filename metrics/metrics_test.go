@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+
+	helvargo "github.com/nuqz/helvar-go"
+	"github.com/nuqz/helvar-go/message"
+	ht "github.com/nuqz/helvar-go/testing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	defaultNet := ht.MustNetFromYAMLFile(
+		path.Join("..", "testing", "test_net.yml"))
+	fakeSrv := ht.NewRouter("", defaultNet)
+
+	client := helvargo.NewClient("127.0.0.1",
+		helvargo.WithConnFactory(fakeSrv.Dial),
+		helvargo.WithPoolSize(2), helvargo.WithQueueSize(4))
+	collector := NewCollector(client, "")
+
+	_, err := client.Connect()
+	require.NoError(t, err)
+	defer client.Disconnect()
+
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(collector))
+
+	_, err = client.GetGroups()
+	require.NoError(t, err)
+	_, err = client.Command(message.QueryWorkgroupName)
+	require.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.requests.WithLabelValues(
+		message.QueryGroups.String(), ResultOK, "")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.requests.WithLabelValues(
+		message.QueryWorkgroupName.String(), ResultReplyError,
+		fmt.Sprint(int(message.EInvalidMessageCommand)))))
+
+	expected := `
+# HELP helvar_client_queue_capacity Number of messages, which may wait for a free transceiver.
+# TYPE helvar_client_queue_capacity gauge
+helvar_client_queue_capacity{router="127.0.0.1:50000"} 24
+# HELP helvar_client_transceivers Number of running transceivers.
+# TYPE helvar_client_transceivers gauge
+helvar_client_transceivers{router="127.0.0.1:50000"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry,
+		strings.NewReader(expected),
+		"helvar_client_queue_capacity", "helvar_client_transceivers"))
+
+	n, err := testutil.GatherAndCount(registry,
+		"helvar_client_request_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
@@ -0,0 +1,154 @@
+package helvargo
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+)
+
+const (
+	// DefaultPort is the HelvarNET port of routers over TCP.
+	DefaultPort = 50000
+	// DefaultUDPPort is the HelvarNET port of routers over UDP.
+	DefaultUDPPort = 50001
+
+	// DefaultPoolSize is the default number of connections to a router.
+	DefaultPoolSize = 1
+	// DefaultQueueSize is the default number of requests of every priority,
+	// which may wait for each connection without blocking callers.
+	DefaultQueueSize = 64
+)
+
+// Transport is a network protocol a client communicates to a router over.
+type Transport uint8
+
+const (
+	TransportTCP Transport = iota
+	TransportUDP
+)
+
+// String returns the name of the network as it is known to package net.
+func (t Transport) String() string {
+	if t == TransportUDP {
+		return "udp"
+	}
+
+	return "tcp"
+}
+
+func (t Transport) defaultPort() int {
+	if t == TransportUDP {
+		return DefaultUDPPort
+	}
+
+	return DefaultPort
+}
+
+// Dialer establishes connections to routers, *net.Dialer is the default
+// one. A custom dialer may bind a local address, tunnel connections, etc.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// ConnFactory establishes connections to a router instead of a dialer, e.g.
+// in memory. The context carries the dial timeout, if any.
+type ConnFactory func(ctx context.Context) (net.Conn, error)
+
+// Option configures a client, see NewClient.
+type Option func(*Client)
+
+// WithLogger sets a logger of a client. Connection lifecycle is logged at
+// info level, retries, desyncs and connection resets are logged as
+// warnings. By default nothing is logged, nil logger means the default.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) {
+		if l == nil {
+			l = discardLogger
+		}
+		c.log = l
+	}
+}
+
+// WithPoolSize sets the number of connections to the router, requests are
+// sent over them concurrently. Requests of the same group or device are
+// always sent over the same connection, so the router receives them in
+// order. The order is kept per target only, e.g. requests to a group and
+// to a device within it may be reordered, as well as requests of different
+// priorities, see WithPriority. It is DefaultPoolSize by default,
+// Connect fails with ErrInvalidOption, unless it is positive.
+func WithPoolSize(n int) Option {
+	return func(c *Client) { c.poolSize = n }
+}
+
+// WithQueueSize sets the number of requests of every priority, which may
+// wait for each connection without blocking callers. It is DefaultQueueSize
+// by default, Connect fails with ErrInvalidOption, when it is negative.
+func WithQueueSize(n int) Option {
+	return func(c *Client) { c.queueSize = n }
+}
+
+// WithKeepAlive sets the interval of keep alive probes of idle connections
+// and a function returning a probe message. The interval is
+// KeepAliveDuration by default, negative one disables probes. Nil probe
+// means QueryTime, which is the default. A probe waits for its reply up to
+// the read timeout or, when there is none, up to the interval.
+func WithKeepAlive(
+	interval time.Duration,
+	probe func() *message.Message,
+) Option {
+	return func(c *Client) {
+		c.keepAlive = interval
+		if probe != nil {
+			c.probeMessage = probe
+		}
+	}
+}
+
+// WithDialTimeout limits the duration of establishing a connection, there is
+// no limit by default besides the one of the operating system.
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *Client) { c.dialTimeout = d }
+}
+
+// WithReadTimeout limits the time a reply is waited for in every attempt,
+// along with the deadline of a call. There is no limit by default.
+func WithReadTimeout(d time.Duration) Option {
+	return func(c *Client) { c.readTimeout = d }
+}
+
+// WithWriteTimeout limits the time a request is sent for, see
+// WithReadTimeout.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(c *Client) { c.writeTimeout = d }
+}
+
+// WithTransport sets the network protocol, it is TCP by default. The
+// default port depends on it, unless the port is given to NewClient.
+func WithTransport(t Transport) Option {
+	return func(c *Client) { c.transport = t }
+}
+
+// WithDialer sets a dialer of connections to the router.
+func WithDialer(d Dialer) Option {
+	return func(c *Client) { c.dialer = d }
+}
+
+// WithConnFactory sets a factory of connections to the router, it overrides
+// the dialer and the transport. The address of the client identifies the
+// router then, see Workgroup.
+func WithConnFactory(f ConnFactory) Option {
+	return func(c *Client) { c.connFactory = f }
+}
+
+// discardHandler drops all records, it is used by default.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discardLogger = slog.New(discardHandler{})
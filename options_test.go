@@ -0,0 +1,159 @@
+package helvargo
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClientAddress(t *testing.T) {
+	for tcDescription, tc := range map[string]struct {
+		addr     string
+		opts     []Option
+		expected string
+	}{
+		"host":           {addr: "10.254.1.2", expected: "10.254.1.2:50000"},
+		"host and port":  {addr: "10.254.1.2:4000", expected: "10.254.1.2:4000"},
+		"hostname":       {addr: "localhost", expected: "localhost:50000"},
+		"ipv6":           {addr: "::1", expected: "[::1]:50000"},
+		"ipv6 and port":  {addr: "[::1]:4000", expected: "[::1]:4000"},
+		"ipv6 bracketed": {addr: "[::1]", expected: "[::1]:50000"},
+		"udp": {
+			addr:     "10.254.1.2",
+			opts:     []Option{WithTransport(TransportUDP)},
+			expected: "10.254.1.2:50001",
+		},
+		"udp and port": {
+			addr:     "10.254.1.2:4000",
+			opts:     []Option{WithTransport(TransportUDP)},
+			expected: "10.254.1.2:4000",
+		},
+	} {
+		t.Run(tcDescription, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NewClient(tc.addr, tc.opts...).Address())
+		})
+	}
+}
+
+type countingDialer struct {
+	net.Dialer
+	dials atomic.Int32
+}
+
+func (d *countingDialer) DialContext(
+	ctx context.Context,
+	network, address string,
+) (net.Conn, error) {
+	d.dials.Add(1)
+	return d.Dialer.DialContext(ctx, network, address)
+}
+
+func TestWithDialer(t *testing.T) {
+	dialer := &countingDialer{}
+	c := serveScript(t, replyGroups, WithDialer(dialer), WithPoolSize(2))
+
+	_, err := c.GetGroups()
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), dialer.dials.Load())
+}
+
+func TestWithReadTimeout(t *testing.T) {
+	var silent atomic.Bool
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		if silent.Load() {
+			time.Sleep(time.Second)
+		}
+		return replyGroups(nConn, req)
+	}, WithReadTimeout(20*time.Millisecond))
+	c.SetRetryPolicy(NoRetries)
+
+	_, err := c.GetGroups()
+	require.NoError(t, err)
+
+	silent.Store(true)
+	start := time.Now()
+	_, err = c.GetGroups()
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWithKeepAlive(t *testing.T) {
+	probes := make(chan message.CommandID, 8)
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		probes <- req.GetCommandID()
+		return (&message.Message{
+			Type:       message.TReply,
+			Parameters: req.Parameters,
+			Answer:     "1",
+		}).String()
+	}, WithKeepAlive(10*time.Millisecond, func() *message.Message {
+		return message.NewQueryClusters()
+	}))
+
+	assert.Equal(t, message.QueryClusters, <-probes)
+	require.Eventually(t, func() bool {
+		return c.Health().Connections[0].KeepAlive.RTT > 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestWithKeepAliveMalformedTime(t *testing.T) {
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		return (&message.Message{
+			Type:       message.TReply,
+			Parameters: req.Parameters,
+			Answer:     "noon",
+		}).String()
+	}, WithKeepAlive(10*time.Millisecond, message.NewQueryTime))
+
+	require.Eventually(t, func() bool {
+		return c.Stats().KeepAliveFailures > 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestKeepAliveDoesntBlockCalls(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	probed := make(chan struct{}, 1)
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		select {
+		case probed <- struct{}{}:
+		default:
+		}
+		<-block
+		return ""
+	}, WithPoolSize(1), WithKeepAlive(50*time.Millisecond, nil))
+	<-probed
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.TransceiveContext(ctx, message.NewQueryGroups())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for tcDescription, opt := range map[string]Option{
+		"no connections":      WithPoolSize(0),
+		"negative queue size": WithQueueSize(-1),
+	} {
+		c := NewClient("localhost", opt)
+		_, err := c.Connect()
+		assert.ErrorIs(t, err, ErrInvalidOption, tcDescription)
+		_, err = c.Transceive(message.NewQueryGroups())
+		assert.ErrorIs(t, err, ErrNotConnected, tcDescription)
+	}
+
+	c := serveScript(t, replyGroups, WithLogger(nil))
+	_, err := c.GetGroups()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,193 @@
+package helvargo
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/nuqz/chanfan"
+	"github.com/nuqz/helvar-go/message"
+)
+
+// Priority of a request in the queue of a client. Requests of higher
+// priority are handed to transceivers first.
+type Priority uint8
+
+const (
+	// PriorityLow is meant for background work, e.g. inventory sweeps.
+	PriorityLow Priority = iota
+	// PriorityNormal is the default priority of queries.
+	PriorityNormal
+	// PriorityHigh is the default priority of control commands.
+	PriorityHigh
+
+	nPriorities
+)
+
+// StarvationLimit is the number of requests handed to transceivers in a row
+// from other lanes, while a lane is waiting. Then one request of the waiting
+// lane goes ahead.
+const StarvationLimit = 16
+
+type priorityKey struct{}
+
+// WithPriority returns a context, which sets the priority of requests issued
+// with it, overriding the default one. Requests are ordered per target within
+// a priority only, so a request with an overridden priority may overtake or
+// fall behind earlier requests to the same target.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// priorityOf returns the priority of a message sent with a given context.
+func priorityOf(ctx context.Context, msg *message.Message) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p < nPriorities {
+		return p
+	}
+
+	if spec, ok := message.LookupCommand(msg.GetCommandID()); ok &&
+		spec.Kind == message.KindControl {
+		return PriorityHigh
+	}
+
+	return PriorityNormal
+}
+
+type queueItem = *chanfan.IO[*Request, *message.Message]
+
+// scheduler replaces a single FIFO queue with a lane per priority. It hands
+// queued requests one by one to an unbuffered channel read by transceivers,
+// so the choice of the next request is made when a transceiver is free.
+type scheduler struct {
+	lanes [nPriorities]chan queueItem
+	out   chan<- queueItem
+
+	// skipped is owned by run, it is the number of requests handed out in
+	// a row from other lanes, while a lane was not empty.
+	skipped [nPriorities]int
+
+	// load is the number of requests queued or being sent.
+	load atomic.Int32
+}
+
+func newScheduler(laneSize int, out chan<- queueItem) *scheduler {
+	s := &scheduler{out: out}
+	for i := range s.lanes {
+		s.lanes[i] = make(chan queueItem, laneSize)
+	}
+
+	return s
+}
+
+// lane returns a channel for requests of a given priority.
+func (s *scheduler) lane(p Priority) chan<- queueItem { return s.lanes[p] }
+
+func (s *scheduler) depth() (out int) {
+	for _, lane := range s.lanes {
+		out += len(lane)
+	}
+
+	return out
+}
+
+func (s *scheduler) capacity() (out int) {
+	for _, lane := range s.lanes {
+		out += cap(lane)
+	}
+
+	return out
+}
+
+// close closes all lanes, requests already queued are handed out anyway.
+func (s *scheduler) close() {
+	for _, lane := range s.lanes {
+		close(lane)
+	}
+}
+
+// run hands requests out until all lanes are closed and drained.
+func (s *scheduler) run() {
+	defer close(s.out)
+
+	lanes := s.lanes
+	for {
+		item, ok := s.next(&lanes)
+		if !ok {
+			return
+		}
+
+		s.out <- item
+	}
+}
+
+// next returns the next request to hand out, lanes, which are closed and
+// drained, are set to nil.
+func (s *scheduler) next(lanes *[nPriorities]chan queueItem) (queueItem, bool) {
+	for {
+		if item, ok := s.poll(lanes); ok {
+			return item, true
+		}
+
+		open := 0
+		for _, lane := range lanes {
+			if lane != nil {
+				open++
+			}
+		}
+		if open == 0 {
+			return nil, false
+		}
+
+		// All lanes are empty, wait for any request. Lanes are few, so a
+		// select of each of them is the simplest.
+		var (
+			item queueItem
+			ok   bool
+			p    int
+		)
+		select {
+		case item, ok = <-lanes[PriorityHigh]:
+			p = int(PriorityHigh)
+		case item, ok = <-lanes[PriorityNormal]:
+			p = int(PriorityNormal)
+		case item, ok = <-lanes[PriorityLow]:
+			p = int(PriorityLow)
+		}
+		if ok {
+			return item, true
+		}
+		lanes[p] = nil
+	}
+}
+
+// poll returns a request of the highest priority available without
+// blocking, unless some lower priority lane has been passed over too many
+// times in a row.
+func (s *scheduler) poll(lanes *[nPriorities]chan queueItem) (queueItem, bool) {
+	pick := -1
+	for p := int(nPriorities) - 1; p >= 0; p-- {
+		if lanes[p] == nil || len(lanes[p]) == 0 {
+			continue
+		}
+
+		if pick < 0 {
+			pick = p
+		}
+		if s.skipped[p] >= StarvationLimit {
+			pick = p
+			break
+		}
+	}
+	if pick < 0 {
+		return nil, false
+	}
+
+	for p, lane := range lanes {
+		if p != pick && lane != nil && len(lane) > 0 {
+			s.skipped[p]++
+		}
+	}
+	s.skipped[pick] = 0
+
+	// The lane isn't empty, so it yields a request even if it is closed.
+	return <-lanes[pick], true
+}
@@ -0,0 +1,85 @@
+package helvargo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nuqz/chanfan"
+	"github.com/nuqz/helvar-go/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityOf(t *testing.T) {
+	ctx := context.Background()
+	for tcDescription, tc := range map[string]struct {
+		ctx      context.Context
+		msg      *message.Message
+		expected Priority
+	}{
+		"control": {
+			ctx:      ctx,
+			msg:      message.NewRecallSceneGroup(1, 1, 1),
+			expected: PriorityHigh,
+		},
+		"query": {
+			ctx:      ctx,
+			msg:      message.NewQueryDeviceDescription("1.2.3.4"),
+			expected: PriorityNormal,
+		},
+		"background query": {
+			ctx:      WithPriority(ctx, PriorityLow),
+			msg:      message.NewQueryDeviceDescription("1.2.3.4"),
+			expected: PriorityLow,
+		},
+		"urgent query": {
+			ctx:      WithPriority(ctx, PriorityHigh),
+			msg:      message.NewQueryTime(),
+			expected: PriorityHigh,
+		},
+		"invalid priority": {
+			ctx:      WithPriority(ctx, 42),
+			msg:      message.NewQueryTime(),
+			expected: PriorityNormal,
+		},
+	} {
+		assert.Equal(t, tc.expected, priorityOf(tc.ctx, tc.msg), tcDescription)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	out := make(chan queueItem)
+	s := newScheduler(64, out)
+
+	labels := map[queueItem]string{}
+	queue := func(p Priority, n int) {
+		for i := 0; i < n; i++ {
+			item := chanfan.NewIO[*Request, *message.Message](nil, nil)
+			labels[item] = string("LNH"[p])
+			s.lane(p) <- item
+		}
+	}
+	queue(PriorityLow, 2)
+	queue(PriorityNormal, 2)
+	queue(PriorityHigh, 2*StarvationLimit)
+	s.close()
+	go s.run()
+
+	order := ""
+	for item := range out {
+		order += labels[item]
+	}
+
+	// Normal and low requests go ahead, once they are passed over
+	// StarvationLimit times, including by each other.
+	require.Len(t, order, 2*StarvationLimit+4)
+	high := func(n int) string {
+		out := ""
+		for i := 0; i < n; i++ {
+			out += "H"
+		}
+		return out
+	}
+	assert.Equal(t,
+		high(StarvationLimit)+"NL"+high(StarvationLimit-1)+"NLH", order)
+}
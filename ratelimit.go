@@ -0,0 +1,188 @@
+package helvargo
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+)
+
+// ErrRateLimited is returned in LimitFail mode, when a request exceeds the
+// rate limit of its kind.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimit is a token bucket: Rate tokens per second are added to it up to
+// Burst, every request sent takes one token. Zero rate means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// LimitMode tells the client what to do with requests over the limit.
+type LimitMode uint8
+
+const (
+	// LimitBlock delays requests until a token is available or a context of
+	// a call is done.
+	LimitBlock LimitMode = iota
+	// LimitFail rejects requests with ErrRateLimited right away.
+	LimitFail
+)
+
+// RateLimits paces requests sent to a router. Queries and control commands
+// have separate budgets, so a flood of colour changes doesn't starve status
+// polling and vice versa. Retries take tokens as well, keep alive probes
+// don't.
+type RateLimits struct {
+	Query   RateLimit
+	Control RateLimit
+	Mode    LimitMode
+}
+
+// NoRateLimits disables rate limiting, it is the default.
+var NoRateLimits = RateLimits{}
+
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(l RateLimit) *bucket {
+	if l.Rate <= 0 {
+		return nil
+	}
+
+	burst := math.Max(1, float64(l.Burst))
+	return &bucket{rate: l.Rate, burst: burst, tokens: burst}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst,
+			b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// take takes a token if there is one.
+func (b *bucket) take(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// reserve takes a token in advance and returns a delay after which it is
+// actually available.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token, which wasn't used.
+func (b *bucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// limiterStats outlive limiters, which are replaced on every change of
+// limits.
+type limiterStats struct {
+	waits    atomic.Int64
+	waitTime atomic.Int64
+	rejected atomic.Int64
+}
+
+type limiter struct {
+	query, control *bucket
+	mode           LimitMode
+	stats          *limiterStats
+}
+
+func newLimiter(l RateLimits, stats *limiterStats) *limiter {
+	return &limiter{
+		query:   newBucket(l.Query),
+		control: newBucket(l.Control),
+		mode:    l.Mode,
+		stats:   stats,
+	}
+}
+
+// wait returns nil, when a message may be sent.
+func (l *limiter) wait(ctx context.Context, msg *message.Message) error {
+	b := l.control
+	if spec, ok := message.LookupCommand(msg.GetCommandID()); ok &&
+		spec.Kind == message.KindQuery {
+		b = l.query
+	}
+	if b == nil {
+		return nil
+	}
+
+	now := time.Now()
+	if l.mode == LimitFail {
+		if !b.take(now) {
+			l.stats.rejected.Add(1)
+			return errors.Wrapf(ErrRateLimited, "refused to send: %s", msg)
+		}
+		return nil
+	}
+
+	delay := b.reserve(now)
+	if delay == 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+		// There is no point to wait for a token, which would come too late.
+		b.cancel()
+		l.stats.rejected.Add(1)
+		return errors.Wrapf(context.DeadlineExceeded,
+			"rate limit wait exceeds deadline: %s", msg)
+	}
+
+	l.stats.waits.Add(1)
+	err := sleep(ctx, delay)
+	l.stats.waitTime.Add(int64(time.Since(now)))
+	if err != nil {
+		b.cancel()
+		return errors.Wrapf(err, "rate limit wait interrupted: %s", msg)
+	}
+
+	return nil
+}
+
+// SetRateLimits sets limits of requests sent to the router, by default there
+// are no limits.
+func (c *Client) SetRateLimits(l RateLimits) {
+	c.limiter.Store(newLimiter(l, &c.limiterStats))
+}
+
+// WithRateLimits sets limits of requests sent to the router, see
+// SetRateLimits.
+func WithRateLimits(l RateLimits) Option {
+	return func(c *Client) { c.SetRateLimits(l) }
+}
@@ -0,0 +1,84 @@
+package helvargo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Now()
+	b := newBucket(RateLimit{Rate: 10, Burst: 2})
+
+	assert.True(t, b.take(now))
+	assert.True(t, b.take(now))
+	assert.False(t, b.take(now))
+	assert.False(t, b.take(now.Add(50*time.Millisecond)))
+	assert.True(t, b.take(now.Add(100*time.Millisecond)))
+
+	now = now.Add(time.Second)
+	assert.Zero(t, b.reserve(now))
+	assert.Zero(t, b.reserve(now))
+	assert.Equal(t, 100*time.Millisecond, b.reserve(now))
+	assert.Equal(t, 200*time.Millisecond, b.reserve(now))
+	b.cancel()
+	assert.Equal(t, 200*time.Millisecond, b.reserve(now))
+
+	assert.Nil(t, newBucket(RateLimit{}))
+}
+
+func replyGroups(nConn int, req *message.Message) string {
+	return "?V:1,C:165=1,2,3#"
+}
+
+func TestRateLimitFail(t *testing.T) {
+	c := serveScript(t, replyGroups, WithRateLimits(RateLimits{
+		Query:   RateLimit{Rate: 0.1, Burst: 2},
+		Control: RateLimit{Rate: 0.1, Burst: 1},
+		Mode:    LimitFail,
+	}))
+
+	for i := 0; i < 2; i++ {
+		_, err := c.GetGroups()
+		require.NoError(t, err)
+	}
+	_, err := c.GetGroups()
+	assert.True(t, errors.Is(err, ErrRateLimited), err)
+
+	// Control commands have a budget of their own.
+	_, err = c.Transceive(message.NewDirectLevelGroup(1, 50))
+	require.NoError(t, err)
+	_, err = c.Transceive(message.NewDirectLevelGroup(1, 50))
+	assert.True(t, errors.Is(err, ErrRateLimited), err)
+
+	assert.Equal(t, int64(2), c.Stats().RateLimited)
+}
+
+func TestRateLimitBlock(t *testing.T) {
+	c := serveScript(t, replyGroups)
+	c.SetRateLimits(RateLimits{Query: RateLimit{Rate: 20, Burst: 1}})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := c.GetGroups()
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	stats := c.Stats()
+	assert.Equal(t, int64(2), stats.LimiterWaits)
+	assert.Positive(t, stats.LimiterWaitTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	_, err := c.GetGroups()
+	require.NoError(t, err)
+	_, err = c.TransceiveContext(ctx, message.NewQueryGroups())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+}
@@ -0,0 +1,101 @@
+package helvargo
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/nuqz/helvar-go/message"
+)
+
+// RetryPolicy tells the client when and how to retry failed requests. Only
+// transport failures are retried: errors replied by a router, invalid
+// messages and done contexts are returned right away.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts per request, values below
+	// 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is a delay before the first retry, it grows by
+	// Multiplier with every next retry up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// AttemptTimeout limits the duration of a single attempt, zero means no
+	// limit other than a deadline of the context of a call.
+	AttemptTimeout time.Duration
+
+	// RetryControl enables retries of control commands for all calls. Those
+	// are not idempotent (e.g. a fade would restart), therefore by default
+	// only queries are retried. See also WithControlRetries.
+	RetryControl bool
+}
+
+// DefaultRetryPolicy retries queries twice with exponential backoff.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Multiplier:     2,
+}
+
+// NoRetries disables retries completely.
+var NoRetries = RetryPolicy{MaxAttempts: 1}
+
+// Backoff returns a delay before a given retry, the first retry is 1.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	multiplier := math.Max(1, p.Multiplier)
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+
+	return time.Duration(d)
+}
+
+// attempts returns the number of attempts allowed for a given message.
+func (p RetryPolicy) attempts(ctx context.Context, msg *message.Message) int {
+	if p.MaxAttempts < 2 {
+		return 1
+	}
+
+	spec, ok := message.LookupCommand(msg.GetCommandID())
+	if !ok {
+		return 1
+	}
+
+	if spec.Kind == message.KindControl &&
+		!p.RetryControl && !controlRetriesFrom(ctx) {
+		return 1
+	}
+
+	return p.MaxAttempts
+}
+
+type controlRetriesKey struct{}
+
+// WithControlRetries returns a context, which opts in to retries of control
+// commands issued with it. It is a per call alternative of
+// RetryPolicy.RetryControl.
+func WithControlRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, controlRetriesKey{}, true)
+}
+
+func controlRetriesFrom(ctx context.Context) bool {
+	v, _ := ctx.Value(controlRetriesKey{}).(bool)
+	return v
+}
+
+// sleep waits for a given duration or until a context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
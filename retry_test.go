@@ -0,0 +1,189 @@
+package helvargo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuqz/helvar-go/members"
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveScript starts a TCP server, which passes every incoming request to
+// handle along with the number of the connection it came from (starting
+// with 1). Handle returns a reply to write or "" to drop the connection,
+// replies to messages, which don't need one, are ignored.
+func serveScript(
+	t *testing.T,
+	handle func(nConn int, req *message.Message) string,
+	opts ...Option,
+) *Client {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	var nConns int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			n := int(atomic.AddInt32(&nConns, 1))
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					raw, err := r.ReadString(terminatorByte)
+					if err != nil {
+						return
+					}
+					req, err := message.Parse(raw)
+					require.NoError(t, err)
+
+					reply := handle(n, req)
+					if !message.NeedResponse(req) {
+						continue
+					}
+					if reply == "" {
+						return
+					}
+					if _, err := conn.Write([]byte(reply)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	c := NewClient(ln.Addr().String(), opts...)
+	_, err = c.Connect()
+	require.NoError(t, err)
+	t.Cleanup(c.Disconnect)
+
+	return c
+}
+
+var fastRetries = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	Multiplier:     2,
+}
+
+func TestRetryQuery(t *testing.T) {
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		if nConn == 1 {
+			return ""
+		}
+		return "?V:1,C:165=1,2,3#"
+	})
+	c.SetRetryPolicy(fastRetries)
+
+	groups, err := c.GetGroups()
+	require.NoError(t, err)
+	assert.Len(t, groups, 3)
+}
+
+func TestRetryIsLogged(t *testing.T) {
+	var logged bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logged,
+		&slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		if nConn == 1 {
+			return ""
+		}
+		return "?V:1,C:165=1,2,3#"
+	}, WithLogger(logger))
+	c.SetRetryPolicy(fastRetries)
+
+	_, err := c.GetGroups()
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(logged.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "level=WARN msg=\"connection reset\"")
+	assert.Contains(t, lines[0], "transceiver=1")
+	assert.Contains(t, lines[1], "level=WARN msg=\"retrying request\"")
+	assert.Contains(t, lines[1], "attempt=1")
+	assert.Contains(t, lines[1], "router=127.0.0.1:")
+}
+
+func TestNoRetries(t *testing.T) {
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		if nConn == 1 {
+			return ""
+		}
+		return "?V:1,C:165=1,2,3#"
+	})
+	c.SetRetryPolicy(NoRetries)
+
+	_, err := c.GetGroups()
+	assert.Error(t, err)
+}
+
+func TestReplyErrorIsNotRetried(t *testing.T) {
+	var nRequests int32
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		atomic.AddInt32(&nRequests, 1)
+		return "!V:1,C:105,G:9999=1#"
+	})
+	c.SetRetryPolicy(fastRetries)
+
+	_, err := c.GetGroupName(members.Group{ID: 9999})
+	var replyErr *ReplyError
+	require.ErrorAs(t, err, &replyErr)
+	assert.Equal(t, message.EInvalidGroupIndex, replyErr.Code)
+	assert.ErrorIs(t, err, message.EInvalidGroupIndex)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&nRequests))
+}
+
+func TestCallDeadline(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	c := serveScript(t, func(nConn int, req *message.Message) string {
+		<-block
+		return ""
+	})
+	c.SetRetryPolicy(fastRetries)
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.TransceiveContext(ctx, message.NewQueryGroups())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     3,
+	}
+	assert.Equal(t, 100*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 300*time.Millisecond, p.Backoff(2))
+	assert.Equal(t, 900*time.Millisecond, p.Backoff(3))
+	assert.Equal(t, time.Second, p.Backoff(4))
+
+	ctx := context.Background()
+	assert.Equal(t, 5, p.attempts(ctx, message.NewQueryGroups()))
+	assert.Equal(t, 1, p.attempts(ctx, message.NewDirectLevelGroup(1, 1)))
+	assert.Equal(t, 5, p.attempts(WithControlRetries(ctx),
+		message.NewDirectLevelGroup(1, 1)))
+	assert.Equal(t, 1, NoRetries.attempts(ctx, message.NewQueryGroups()))
+}
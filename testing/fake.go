@@ -0,0 +1,467 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"sync"
+	"time"
+
+	"github.com/nuqz/helvar-go/colour"
+	"github.com/nuqz/helvar-go/members"
+	"github.com/nuqz/helvar-go/message"
+)
+
+// Handler replies to a message sent to Fake. Nil reply means the message is
+// not answered, an error is returned to the caller as is, like a transport
+// error of a real client.
+type Handler func(msg *message.Message) (*message.Message, error)
+
+// Fake is an in-memory double of a client, which implements helvargo.API.
+// It records every message sent through it and replies to queries from a
+// network just like Router does, unless replies to some commands are
+// programmed with Handle, Answer, Fail or ReplyError. There are no
+// connections, retries or interceptors, colours are not clamped.
+//
+// Error replies are returned as *message.ReplyError (helvargo.ReplyError),
+// just like a real client returns them.
+type Fake struct {
+	router *Router
+
+	mu       sync.Mutex
+	sent     []*message.Message
+	handlers map[message.CommandID]Handler
+}
+
+// TestingT is the part of *testing.T used by assertions of Fake.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// NewFake returns a fake client of a router with a given network.
+func NewFake(net Network) *Fake {
+	return &Fake{
+		router:   NewRouter("", net),
+		handlers: map[message.CommandID]Handler{},
+	}
+}
+
+// Handle programs replies to messages of a given command.
+func (f *Fake) Handle(id message.CommandID, h Handler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.handlers[id] = h
+}
+
+// Answer programs a reply with a given answer to messages of a given
+// command.
+func (f *Fake) Answer(id message.CommandID, answer string) {
+	f.Handle(id, func(msg *message.Message) (*message.Message, error) {
+		return &message.Message{
+			Type:       message.TReply,
+			Parameters: msg.Parameters,
+			Answer:     answer,
+		}, nil
+	})
+}
+
+// ReplyError programs an error reply with a given code to messages of a
+// given command.
+func (f *Fake) ReplyError(id message.CommandID, code message.ErrorID) {
+	f.Handle(id, func(msg *message.Message) (*message.Message, error) {
+		return &message.Message{
+			Type:       message.TError,
+			Parameters: msg.Parameters,
+			Answer:     fmt.Sprint(int(code)),
+		}, nil
+	})
+}
+
+// Fail programs a given error to be returned for messages of a given
+// command, e.g. to simulate a lost connection.
+func (f *Fake) Fail(id message.CommandID, err error) {
+	f.Handle(id, func(*message.Message) (*message.Message, error) {
+		return nil, err
+	})
+}
+
+// Sent returns messages sent so far in order.
+func (f *Fake) Sent() []*message.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*message.Message(nil), f.sent...)
+}
+
+// SentCommands returns messages of a given command sent so far in order.
+func (f *Fake) SentCommands(id message.CommandID) []*message.Message {
+	out := []*message.Message{}
+	for _, msg := range f.Sent() {
+		if msg.GetCommandID() == id {
+			out = append(out, msg)
+		}
+	}
+
+	return out
+}
+
+// Reset forgets messages sent so far, programmed replies are kept.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = nil
+}
+
+// AssertSent asserts, that exactly given messages have been sent in order.
+// Messages are compared by their wire format.
+func (f *Fake) AssertSent(t TestingT, expected ...*message.Message) bool {
+	t.Helper()
+
+	sent := f.Sent()
+	ok := len(sent) == len(expected)
+	for i := 0; ok && i < len(sent); i++ {
+		ok = sent[i].String() == expected[i].String()
+	}
+	if !ok {
+		t.Errorf("sent messages differ:\nexpected: %v\nactual:   %v",
+			expected, sent)
+	}
+
+	return ok
+}
+
+// AssertSentMessage asserts, that a given message has been sent at least
+// once.
+func (f *Fake) AssertSentMessage(t TestingT, expected *message.Message) bool {
+	t.Helper()
+
+	sent := f.Sent()
+	for _, msg := range sent {
+		if msg.String() == expected.String() {
+			return true
+		}
+	}
+
+	t.Errorf("message %s was not sent, sent messages: %v", expected, sent)
+	return false
+}
+
+func (f *Fake) Transceive(msg *message.Message) (*message.Message, error) {
+	return f.TransceiveContext(context.Background(), msg)
+}
+
+// TransceiveContext records a message and returns a reply to it. Invalid
+// messages are rejected without being recorded, like a real client does.
+func (f *Fake) TransceiveContext(
+	ctx context.Context,
+	msg *message.Message,
+) (*message.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := message.Validate(msg); err != nil {
+		return nil, fmt.Errorf("refused to send invalid message: %w", err)
+	}
+
+	f.mu.Lock()
+	f.sent = append(f.sent, msg)
+	h := f.handlers[msg.GetCommandID()]
+	f.mu.Unlock()
+
+	var reply *message.Message
+	if h != nil {
+		var err error
+		if reply, err = h(msg); err != nil {
+			return nil, err
+		}
+	} else if message.NeedResponse(msg) {
+		reply = f.router.respond(msg)
+	}
+
+	if reply != nil && reply.Type == message.TError {
+		return nil, message.NewReplyError(reply)
+	}
+
+	return reply, nil
+}
+
+func (f *Fake) Command(
+	id message.CommandID,
+	params ...message.Parameter,
+) (*message.Message, error) {
+	msg, err := message.New(id, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.Transceive(msg)
+}
+
+func (f *Fake) queryIDs(msg *message.Message) ([]int, error) {
+	reply, err := f.Transceive(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply.AnswerIDs()
+}
+
+func (f *Fake) GetClusters() ([]members.Cluster, error) {
+	ids, err := f.queryIDs(message.NewQueryClusters())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]members.Cluster, len(ids))
+	for i, id := range ids {
+		out[i] = members.Cluster{ID: uint8(id)}
+	}
+
+	return out, nil
+}
+
+// GetRouters queries routers of every cluster just like a client does.
+func (f *Fake) GetRouters() ([]members.Router, error) {
+	clusters, err := f.GetClusters()
+	if err != nil {
+		return nil, err
+	}
+
+	out := []members.Router{}
+	for _, cluster := range clusters {
+		routers, err := f.GetClusterRouters(cluster.ID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, routers...)
+	}
+
+	return out, nil
+}
+
+func (f *Fake) GetClusterRouters(cluster uint8) ([]members.Router, error) {
+	ids, err := f.queryIDs(message.NewQueryRouters(fmt.Sprint(int(cluster))))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]members.Router, len(ids))
+	for i, id := range ids {
+		out[i] = members.Router{ID: uint8(id), Cluster: cluster}
+	}
+
+	return out, nil
+}
+
+func (f *Fake) GetGroups() ([]members.Group, error) {
+	ids, err := f.queryIDs(message.NewQueryGroups())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]members.Group, len(ids))
+	for i, id := range ids {
+		out[i] = members.Group{ID: uint16(id)}
+	}
+
+	return out, nil
+}
+
+func (f *Fake) GetGroupName(g members.Group) (string, error) {
+	reply, err := f.Transceive(message.NewQueryGroupDescription(g.ID))
+	if err != nil {
+		return "", err
+	}
+
+	return reply.Answer, nil
+}
+
+// GetGroupNames queries names one by one, errors are returned joined.
+func (f *Fake) GetGroupNames(ids []uint16) ([]string, error) {
+	out := make([]string, len(ids))
+	var errs []error
+	for i, id := range ids {
+		name, err := f.GetGroupName(members.Group{ID: id})
+		if err != nil {
+			errs = append(errs, err)
+		}
+		out[i] = name
+	}
+
+	return out, errors.Join(errs...)
+}
+
+func (f *Fake) GetDevices(g members.Group) ([]members.Device, error) {
+	reply, err := f.Transceive(message.NewQueryGroup(g.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	out := []members.Device{}
+	for _, addr := range reply.AnswerAddresses() {
+		out = append(out, members.Device{Address: addr})
+	}
+
+	return out, nil
+}
+
+func (f *Fake) GetDeviceName(d members.Device) (string, error) {
+	reply, err := f.Transceive(message.NewQueryDeviceDescription(d.Address))
+	if err != nil {
+		return "", err
+	}
+
+	return reply.Answer, nil
+}
+
+// GetDeviceNames queries names one by one, errors are returned joined.
+func (f *Fake) GetDeviceNames(addrs []string) ([]string, error) {
+	out := make([]string, len(addrs))
+	var errs []error
+	for i, addr := range addrs {
+		name, err := f.GetDeviceName(members.Device{Address: addr})
+		if err != nil {
+			errs = append(errs, err)
+		}
+		out[i] = name
+	}
+
+	return out, errors.Join(errs...)
+}
+
+func (f *Fake) GetDeviceState(d members.Device) (members.DeviceState, error) {
+	reply, err := f.Transceive(message.NewQueryDeviceState(d.Address))
+	if err != nil {
+		return 0, err
+	}
+
+	state, err := reply.AnswerInt()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query state of %s: %w", d.Address, err)
+	}
+
+	return members.DeviceState(state), nil
+}
+
+func (f *Fake) GetTime() (time.Time, error) {
+	reply, err := f.Transceive(message.NewQueryTime())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ts, err := reply.AnswerInt()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query network time: %w", err)
+	}
+
+	return time.Unix(ts, 0), nil
+}
+
+func (f *Fake) RecallSceneGroup(
+	gid uint16,
+	block, scene uint8,
+	params ...message.Parameter,
+) error {
+	_, err := f.Transceive(
+		message.NewRecallSceneGroup(gid, block, scene, params...))
+	return err
+}
+
+func (f *Fake) RecallSceneDevice(
+	addr string,
+	block, scene uint8,
+	params ...message.Parameter,
+) error {
+	_, err := f.Transceive(
+		message.NewRecallSceneDevice(addr, block, scene, params...))
+	return err
+}
+
+func (f *Fake) DirectLevelGroup(
+	gid uint16,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	_, err := f.Transceive(message.NewDirectLevelGroup(gid, level, params...))
+	return err
+}
+
+func (f *Fake) DirectLevelDevice(
+	addr string,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	_, err := f.Transceive(
+		message.NewDirectLevelDevice(addr, level, params...))
+	return err
+}
+
+func (f *Fake) ColorTemperatureGroup(
+	gid, tempK uint16,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	_, err := f.Transceive(
+		message.NewColorTemperatureGroup(gid, tempK, level, params...))
+	return err
+}
+
+func (f *Fake) ColorTemperatureDevice(
+	addr string,
+	tempK uint16,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	_, err := f.Transceive(
+		message.NewColorTemperatureDevice(addr, tempK, level, params...))
+	return err
+}
+
+func (f *Fake) ColorGroup(
+	gid uint16,
+	color color.Color,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	_, err := f.Transceive(message.NewColorGroup(gid,
+		colour.FromColor(color), level, params...))
+	return err
+}
+
+func (f *Fake) ColorDevice(
+	addr string,
+	color color.Color,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	_, err := f.Transceive(message.NewColorDevice(addr,
+		colour.FromColor(color), level, params...))
+	return err
+}
+
+func (f *Fake) RGBGroup(
+	gid uint16,
+	r, g, b byte,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	_, err := f.Transceive(message.NewColorGroup(gid,
+		colour.FromRGB(r, g, b), level, params...))
+	return err
+}
+
+func (f *Fake) RGBDevice(
+	addr string,
+	r, g, b byte,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	_, err := f.Transceive(message.NewColorDevice(addr,
+		colour.FromRGB(r, g, b), level, params...))
+	return err
+}
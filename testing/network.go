@@ -4,7 +4,6 @@ import (
 	"os"
 
 	"github.com/nuqz/helvar-go/members"
-	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
@@ -36,7 +35,7 @@ func NetFromYAMLFile(path string) (Network, error) {
 func MustNetFromYAMLFile(path string) Network {
 	net, err := NetFromYAMLFile(path)
 	if err != nil {
-		logrus.Fatal(err)
+		panic(err)
 	}
 
 	return net
@@ -60,6 +59,20 @@ func (n Network) GetRouterIDs() []uint8 {
 	return out
 }
 
+// GetClusterRouterIDs returns IDs of routers of a given cluster. Routers
+// without a cluster belong to every cluster, while cluster 0 has all
+// routers.
+func (n Network) GetClusterRouterIDs(cluster uint8) []uint8 {
+	out := []uint8{}
+	for _, r := range n.Routers {
+		if cluster == 0 || r.Cluster == 0 || r.Cluster == cluster {
+			out = append(out, r.ID)
+		}
+	}
+
+	return out
+}
+
 func (n Network) GetGroupIDs() []uint16 {
 	out := make([]uint16, len(n.Groups))
 	for i, g := range n.Groups {
@@ -0,0 +1,90 @@
+package testing
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// Dial connects to the router in memory, without a listener, so a client
+// may use it as its connection factory. Any number of connections may be
+// established this way, along with the ones accepted by Listen.
+func (r *Router) Dial(ctx context.Context) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	conn, srv := net.Pipe()
+	go func() {
+		if err := r.handleClient(newBufferedConn(srv)); err != nil {
+			r.log.Error("failed to handle client", "client", "pipe",
+				"error", err)
+		}
+	}()
+
+	return conn, nil
+}
+
+// bufferedConn writes in the background, since writes to a pipe block until
+// the other side reads. Unlike a TCP connection, the pipe has no buffers of
+// its own, so broadcasts to a client, which doesn't read, would stall the
+// router otherwise.
+type bufferedConn struct {
+	net.Conn
+
+	mu      sync.Mutex
+	pending [][]byte
+	closed  bool
+	signal  chan struct{}
+}
+
+func newBufferedConn(conn net.Conn) *bufferedConn {
+	c := &bufferedConn{Conn: conn, signal: make(chan struct{}, 1)}
+	go c.run()
+
+	return c
+}
+
+func (c *bufferedConn) Write(bs []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+
+	c.pending = append(c.pending, append([]byte(nil), bs...))
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+
+	return len(bs), nil
+}
+
+func (c *bufferedConn) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.signal)
+	}
+	c.mu.Unlock()
+
+	return c.Conn.Close()
+}
+
+func (c *bufferedConn) run() {
+	for range c.signal {
+		c.mu.Lock()
+		pending := c.pending
+		c.pending = nil
+		c.mu.Unlock()
+
+		for _, bs := range pending {
+			if _, err := c.Conn.Write(bs); err != nil {
+				// The connection is closed by the reader anyway.
+				return
+			}
+		}
+	}
+}
@@ -2,15 +2,17 @@ package testing
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"io"
+	"log/slog"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nuqz/helvar-go/message"
-	"github.com/sirupsen/logrus"
 	"golang.org/x/exp/constraints"
 )
 
@@ -39,14 +41,125 @@ type Router struct {
 	listener  net.Listener
 	listening bool
 
-	log *logrus.Entry
+	mu      sync.Mutex
+	clients map[*client]struct{}
+
+	// queries maps commands supported by the simulator to functions, which
+	// build an answer for them.
+	queries map[message.CommandID]func(*message.Message) string
+
+	log *slog.Logger
+}
+
+// RouterOption configures a router, see NewRouter.
+type RouterOption func(*Router)
+
+// WithLogger sets a logger of a router. Every message received and sent is
+// logged at debug level. By default nothing is logged.
+func WithLogger(l *slog.Logger) RouterOption {
+	return func(r *Router) {
+		r.log = l.With("package", "helvar-go/testing")
+	}
 }
 
-func NewRouter(addr string, net Network) *Router {
-	return &Router{
+// discardHandler drops all records, it is used by default.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+func NewRouter(addr string, net Network, opts ...RouterOption) *Router {
+	r := &Router{
 		Address: addr,
 		net:     net,
-		log:     logrus.New().WithField("package", "helvar-go/testing"),
+		clients: map[*client]struct{}{},
+		log:     slog.New(discardHandler{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.queries = map[message.CommandID]func(*message.Message) string{
+		message.QueryClusters: func(*message.Message) string {
+			return joinIDs(r.net.GetClusterIDs())
+		},
+		message.QueryRouters: func(msg *message.Message) string {
+			cluster, _ := strconv.Atoi(msg.GetAddress())
+			return joinIDs(r.net.GetClusterRouterIDs(uint8(cluster)))
+		},
+		message.QueryGroups: func(*message.Message) string {
+			return joinIDs(r.net.GetGroupIDs())
+		},
+		message.QueryGroupDescription: func(msg *message.Message) string {
+			return r.net.GetGroupByID(msg.GetGroupID()).Name
+		},
+		message.QueryGroup: func(msg *message.Message) string {
+			return joinStrs(r.net.GetGroupDevices(msg.GetGroupID()))
+		},
+		message.QueryDeviceDescription: func(msg *message.Message) string {
+			return r.net.GetDeviceByAddress(msg.GetAddress()).Name
+		},
+		message.QueryDeviceState: func(msg *message.Message) string {
+			state := r.net.GetDeviceByAddress(msg.GetAddress()).State
+			return strconv.Itoa(int(state))
+		},
+		message.QueryTime: func(*message.Message) string {
+			return strconv.Itoa(int(time.Now().Unix()))
+		},
+	}
+
+	return r
+}
+
+// client is a connection to the simulator, writes to which are serialized,
+// because both replies and broadcasts may be written concurrently.
+type client struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func (c *client) write(bs []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn.Write(bs)
+}
+
+// Clients returns the number of clients currently connected to the router.
+func (r *Router) Clients() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.clients)
+}
+
+// Broadcast sends a given message to all connected clients as unsolicited
+// one, just like a real router does when a scene is recalled from a panel or
+// by Designer.
+func (r *Router) Broadcast(msg *message.Message) {
+	r.broadcast(msg, nil)
+}
+
+func (r *Router) broadcast(msg *message.Message, origin *client) {
+	out := (&message.Message{
+		Type:       message.TInternalCommand,
+		Parameters: msg.Parameters,
+	}).Bytes()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for c := range r.clients {
+		if c == origin {
+			continue
+		}
+
+		if _, err := c.write(out); err != nil {
+			r.log.Error("failed to broadcast a message",
+				"client", c.conn.RemoteAddr().String(), "error", err)
+		}
 	}
 }
 
@@ -66,24 +179,24 @@ func (r *Router) Listen() error {
 		defer func() {
 			r.listening = false
 			if err := r.listener.Close(); err != nil {
-				r.log.WithError(err).
-					Error("failed to close listener properly")
+				r.log.Error("failed to close listener properly",
+					"error", err)
 			}
 			r.log.Info("listener stopped")
 		}()
 
-		r.log.Infof("listening @%s", r.Address)
+		r.log.Info("listening", "address", r.Address)
 		for {
 			conn, err := r.listener.Accept()
 			if err != nil {
-				r.log.WithError(err).Error("failed to accept a connection")
+				r.log.Error("failed to accept a connection", "error", err)
 				continue
 			}
 
 			go func() {
 				if err := r.handleClient(conn); err != nil {
-					r.log.WithField("client", conn.RemoteAddr().String()).
-						Error(err)
+					r.log.Error("failed to handle client",
+						"client", conn.RemoteAddr().String(), "error", err)
 				}
 			}()
 		}
@@ -93,14 +206,22 @@ func (r *Router) Listen() error {
 }
 
 func (r *Router) handleClient(conn net.Conn) error {
-	log := r.log.WithField("client", conn.RemoteAddr().String())
+	log := r.log.With("client", conn.RemoteAddr().String())
 	log.Info("handling client")
 
+	cl := &client{conn: conn}
+	r.mu.Lock()
+	r.clients[cl] = struct{}{}
+	r.mu.Unlock()
+
 	defer func() {
+		r.mu.Lock()
+		delete(r.clients, cl)
+		r.mu.Unlock()
+
 		if err := conn.Close(); err != nil {
-			log.WithError(err).
-				WithField("client", conn.RemoteAddr().String()).
-				Error("failed to close client connection properly")
+			log.Error("failed to close client connection properly",
+				"error", err)
 		}
 	}()
 
@@ -112,74 +233,62 @@ func (r *Router) handleClient(conn net.Conn) error {
 		} else if err != nil {
 			return err
 		}
-		log.Infof("received: %s", requestStr)
+		log.Debug("received", "message", requestStr)
 
 		msg, err := message.Parse(requestStr)
 		if err != nil {
-			log.WithError(err).
-				Error("failed to parse incoming message: %s", requestStr)
+			log.Error("failed to parse incoming message",
+				"message", requestStr, "error", err)
 			continue
 		}
 
 		cmdID := msg.GetCommandID()
 
 		// TODO: Don't know if real router may respond with error message.
-		if !message.NeedResponse(msg) {
-			switch cmdID {
-			case message.RecallSceneGroup:
-				// TODO: add something meaningful
-			case message.RecallSceneDevice:
-				// TODO: add something meaningful
-			case message.DirectLevelGroup:
-				// TODO: add something meaningful
-			case message.DirectLevelDevice:
-				// TODO: add something meaningful
-			default:
-				// TODO: Unsupported command error
+		if spec, ok := message.LookupCommand(cmdID); ok && !spec.Answered() {
+			// TODO: add something meaningful for control commands
+			if message.Validate(msg) == nil {
+				r.broadcast(msg, cl)
 			}
 			continue
 		}
 
-		reply := &message.Message{
-			Type:       message.TReply,
-			Parameters: msg.Parameters,
-		}
-
-		switch cmdID {
-		case message.QueryClusters:
-			reply.Answer = joinIDs(r.net.GetClusterIDs())
-		case message.QueryRouters:
-			reply.Answer = joinIDs(r.net.GetRouterIDs())
-		case message.QueryGroups:
-			reply.Answer = joinIDs(r.net.GetGroupIDs())
-		case message.QueryGroupDescription:
-			reply.Answer = r.net.GetGroupByID(msg.GetGroupID()).Name
-		case message.QueryGroup:
-			reply.Answer = joinStrs(r.net.GetGroupDevices(msg.GetGroupID()))
-		case message.QueryDeviceDescription:
-			reply.Answer = r.net.GetDeviceByAddress(msg.GetAddress()).Name
-		case message.QueryTime:
-			reply.Answer = strconv.Itoa(int(time.Now().Unix()))
-		case message.NoCommand:
-			fallthrough
-		default:
-			reply.Type = message.TError
-			reply.Answer = strconv.Itoa(int(message.EInvalidMessageCommand))
-		}
-
+		reply := r.respond(msg)
 		out := reply.Bytes()
-		if n, err := conn.Write(out); err != nil {
-			log.WithError(err).
-				Error("unable to write response %s for incoming message %s",
-					reply, msg)
+		if n, err := cl.write(out); err != nil {
+			log.Error("unable to write response for incoming message",
+				"response", reply, "message", msg, "error", err)
 		} else if n != len(out) {
-			log.Error(
-				"only part of response %s was sent for incoming message %s",
-				reply, msg)
+			log.Error("only part of response was sent for incoming message",
+				"response", reply, "message", msg)
 		} else {
-			log.Infof("sent: %s", reply)
+			log.Debug("sent", "message", reply)
 		}
 	}
 
 	return nil
 }
+
+// respond returns a reply to an answered message, it is an error message for
+// invalid messages and queries, which are not supported by the simulator.
+func (r *Router) respond(msg *message.Message) *message.Message {
+	reply := &message.Message{
+		Type:       message.TReply,
+		Parameters: msg.Parameters,
+	}
+
+	answer, supported := r.queries[msg.GetCommandID()]
+	if err := message.Validate(msg); err != nil {
+		code := message.EInvalidMessageCommand
+		errors.As(err, &code)
+		reply.Type = message.TError
+		reply.Answer = strconv.Itoa(int(code))
+	} else if !supported {
+		reply.Type = message.TError
+		reply.Answer = strconv.Itoa(int(message.EInvalidMessageCommand))
+	} else {
+		reply.Answer = answer(msg)
+	}
+
+	return reply
+}
@@ -0,0 +1,78 @@
+// Package tracing traces requests of a helvargo.Client with OpenTelemetry.
+package tracing
+
+import (
+	"context"
+
+	helvargo "github.com/nuqz/helvar-go"
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of a tracer, which creates spans.
+const InstrumentationName = "github.com/nuqz/helvar-go/tracing"
+
+// Attributes of spans.
+const (
+	CommandIDKey   = attribute.Key("helvar.command.id")
+	CommandNameKey = attribute.Key("helvar.command.name")
+	GroupKey       = attribute.Key("helvar.group")
+	AddressKey     = attribute.Key("helvar.address")
+	RouterKey      = attribute.Key("helvar.router")
+	ErrorCodeKey   = attribute.Key("helvar.error.code")
+)
+
+// Interceptor returns an interceptor, which wraps every request sent to a
+// router at a given address into a span. Spans are children of a span
+// found in a context of a call, if any. Nil provider means the global one.
+//
+//	c.Use(tracing.Interceptor(c.Address(), nil))
+func Interceptor(
+	router string,
+	provider trace.TracerProvider,
+) helvargo.Interceptor {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	tracer := provider.Tracer(InstrumentationName)
+
+	return func(
+		ctx context.Context,
+		msg *message.Message,
+		next helvargo.Invoker,
+	) (*message.Message, error) {
+		cmdID := msg.GetCommandID()
+		attrs := []attribute.KeyValue{
+			CommandIDKey.Int(int(cmdID)),
+			CommandNameKey.String(cmdID.String()),
+			RouterKey.String(router),
+		}
+		if group, ok := msg.GetUint(message.Group); ok {
+			attrs = append(attrs, GroupKey.Int64(int64(group)))
+		}
+		if address := msg.GetAddress(); address != "" {
+			attrs = append(attrs, AddressKey.String(address))
+		}
+
+		ctx, span := tracer.Start(ctx, "HelvarNET "+cmdID.String(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...))
+		defer span.End()
+
+		reply, err := next(ctx, msg)
+		if err != nil {
+			var replyErr *helvargo.ReplyError
+			if errors.As(err, &replyErr) {
+				span.SetAttributes(ErrorCodeKey.Int(int(replyErr.Code)))
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return reply, err
+	}
+}
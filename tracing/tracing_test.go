@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	helvargo "github.com/nuqz/helvar-go"
+	"github.com/nuqz/helvar-go/members"
+	"github.com/nuqz/helvar-go/message"
+	ht "github.com/nuqz/helvar-go/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInterceptor(t *testing.T) {
+	defaultNet := ht.MustNetFromYAMLFile(
+		path.Join("..", "testing", "test_net.yml"))
+	fakeSrv := ht.NewRouter("", defaultNet)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter))
+
+	client := helvargo.NewClient("127.0.0.1",
+		helvargo.WithConnFactory(fakeSrv.Dial))
+	client.Use(Interceptor(client.Address(), provider))
+
+	_, err := client.Connect()
+	require.NoError(t, err)
+	defer client.Disconnect()
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "api")
+	_, err = client.TransceiveContext(ctx, message.NewQueryGroupDescription(1))
+	require.NoError(t, err)
+	parent.End()
+
+	_, err = client.Command(message.QueryWorkgroupName)
+	require.Error(t, err)
+
+	_, err = client.GetDeviceName(members.Device{Address: "1.2.3.4"})
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+
+	groupSpan, apiSpan, errSpan, deviceSpan := spans[0], spans[1], spans[2],
+		spans[3]
+	assert.Equal(t, "HelvarNET QueryGroupDescription", groupSpan.Name)
+	assert.Equal(t, apiSpan.SpanContext.SpanID(), groupSpan.Parent.SpanID())
+	assert.Equal(t, apiSpan.SpanContext.TraceID(),
+		groupSpan.SpanContext.TraceID())
+	assert.Subset(t, groupSpan.Attributes, []attribute.KeyValue{
+		CommandIDKey.Int(int(message.QueryGroupDescription)),
+		CommandNameKey.String("QueryGroupDescription"),
+		GroupKey.Int64(1),
+		RouterKey.String("127.0.0.1:50000"),
+	})
+	assert.Equal(t, codes.Unset, groupSpan.Status.Code)
+
+	assert.False(t, errSpan.Parent.IsValid())
+	assert.Equal(t, codes.Error, errSpan.Status.Code)
+	assert.Contains(t, errSpan.Attributes,
+		ErrorCodeKey.Int(int(message.EInvalidMessageCommand)))
+
+	assert.Contains(t, deviceSpan.Attributes, AddressKey.String("1.2.3.4"))
+}
@@ -2,7 +2,12 @@ package helvargo
 
 import (
 	"bufio"
+	"context"
+	"log/slog"
+	"math"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nuqz/chanfan"
@@ -10,69 +15,400 @@ import (
 	"github.com/pkg/errors"
 )
 
+// KeepAliveDuration is the default interval of keep alive probes of idle
+// connections, see WithKeepAlive.
 const KeepAliveDuration = 120 * time.Second
 
+// Request is a message queued for transceiving along with the context it was
+// issued in. Deadline of the context is applied to the connection, while its
+// cancellation interrupts the exchange.
+type Request struct {
+	Context context.Context
+	Message *message.Message
+
+	// state is claimed once, either by a transceiver about to send the
+	// message or by a caller giving up on it before that.
+	state atomic.Int32
+	// done is closed, when the transceiver doesn't use the message anymore.
+	done chan struct{}
+}
+
+const (
+	requestQueued int32 = iota
+	requestTaken
+	requestAbandoned
+)
+
+// NewRequest returns a new request for a given message.
+func NewRequest(ctx context.Context, msg *message.Message) *Request {
+	return &Request{Context: ctx, Message: msg, done: make(chan struct{})}
+}
+
+// take claims a request for sending, it returns false when the caller has
+// already abandoned it.
+func (r *Request) take() bool {
+	return r.state.CompareAndSwap(requestQueued, requestTaken)
+}
+
+// abandon gives up on a request. If a transceiver has already taken it,
+// abandon waits until the transceiver is done with the message, so the
+// caller may reuse the message afterwards. The wait is short: cancellation
+// of the context interrupts both waiting for the connection and the
+// exchange over it.
+func (r *Request) abandon() {
+	if !r.state.CompareAndSwap(requestQueued, requestAbandoned) {
+		<-r.done
+	}
+}
+
+// DialFunc establishes a new connection to a router. Transceiver calls it
+// once on creation and every time the connection has to be reset.
+type DialFunc func() (net.Conn, error)
+
 type Transceiver struct {
-	*chanfan.Transceiver[*message.Message, *message.Message]
+	*chanfan.Transceiver[*Request, *message.Message]
+
+	dial DialFunc
+	log  *slog.Logger
+
+	// probe returns a keep alive message, zero timeouts mean no limits.
+	probe        func() *message.Message
+	readTimeout  time.Duration
+	writeTimeout time.Duration
 
+	// sem serializes keep alive probes with regular requests, both of them
+	// share the same connection and must not interleave. It is a channel,
+	// so waiting for it can be given up, see lock.
+	sem  chan struct{}
 	conn net.Conn
 	r    *bufio.Reader
+	w    []byte
+
+	alive             atomic.Bool
+	connected         atomic.Bool
+	reconnects        atomic.Int64
+	keepAliveFailures atomic.Int64
+
+	// terminated is closed, when the connection is closed for good, the
+	// error of closing it is in terminateErr then.
+	terminated   chan struct{}
+	terminateErr error
+
+	statusMu sync.Mutex
+	status   ConnectionHealth
 }
 
 var terminatorByte = message.Terminator.Byte()
 
 func NewTransceiver(
-	conn net.Conn,
-	in <-chan *chanfan.IO[*message.Message, *message.Message],
-) *Transceiver {
+	dial DialFunc,
+	in <-chan *chanfan.IO[*Request, *message.Message],
+) (*Transceiver, error) {
+	out := &Transceiver{
+		dial:       dial,
+		log:        discardLogger,
+		probe:      message.NewQueryTime,
+		sem:        make(chan struct{}, 1),
+		terminated: make(chan struct{}),
+	}
+	if err := out.connect(); err != nil {
+		return nil, err
+	}
+
 	t := chanfan.NewTransceiver(in)
 	t.KeepAliveDuration = KeepAliveDuration
 	t.Terminate = func() error {
-		if err := conn.Close(); err != nil {
-			return errors.Wrap(err,
+		out.alive.Store(false)
+
+		// Keep alive probes and requests are bounded, so this doesn't
+		// block for long.
+		_ = out.lock(context.Background())
+		defer out.unlock()
+
+		select {
+		case <-out.terminated:
+			return out.terminateErr
+		default:
+		}
+		defer close(out.terminated)
+
+		if out.conn == nil {
+			return nil
+		}
+
+		if err := out.conn.Close(); err != nil {
+			out.terminateErr = errors.Wrap(err,
 				"failed to close transceiver connection properly")
 		}
-		return nil
+		out.conn = nil
+		out.connected.Store(false)
+		return out.terminateErr
+	}
+
+	out.Transceiver = t
+	return out, nil
+}
+
+func (t *Transceiver) connect() error {
+	conn, err := t.dial()
+	if err != nil {
+		return errors.Wrap(err, "failed to establish connection")
+	}
+
+	t.conn = conn
+	t.connected.Store(true)
+	t.r = bufio.NewReader(conn)
+	t.log.Debug("connection established", "remote", conn.RemoteAddr())
+	return nil
+}
+
+// reset drops current connection, so the next message will be sent over a
+// fresh one. It is used whenever the state of the stream is unknown, e.g.
+// after a transport error or when a reply doesn't match its request.
+func (t *Transceiver) reset(reason error) {
+	if errors.Is(reason, context.Canceled) ||
+		errors.Is(reason, context.DeadlineExceeded) {
+		t.log.Debug("connection reset", "error", reason)
+	} else {
+		t.log.Warn("connection reset", "error", reason)
 	}
 
-	return &Transceiver{
-		Transceiver: t,
+	if t.conn != nil {
+		// The connection is dropped anyway, nothing to do with an error.
+		_ = t.conn.Close()
+	}
+
+	t.conn = nil
+	t.connected.Store(false)
+	t.r = nil
+}
+
+func (t *Transceiver) transceive(req *Request) (*message.Message, error) {
+	if !req.take() {
+		// Nobody waits for the result, don't even touch the message.
+		return nil, req.Context.Err()
+	}
+	defer close(req.done)
+
+	reply, err := t.exchange(req)
+	t.record(req.Context, err)
+	return reply, err
+}
 
-		conn: conn,
-		r:    bufio.NewReader(conn),
+// lock takes the connection, unless a given context is done first.
+func (t *Transceiver) lock(ctx context.Context) error {
+	select {
+	case t.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "failed to wait for connection")
 	}
 }
 
-func (t *Transceiver) transceive(
-	msg *message.Message,
-) (*message.Message, error) {
-	out := msg.Bytes()
+func (t *Transceiver) unlock() { <-t.sem }
+
+// exchange sends a request and receives its reply, if it is answered.
+func (t *Transceiver) exchange(req *Request) (*message.Message, error) {
+	ctx, msg := req.Context, req.Message
+	if err := ctx.Err(); err != nil {
+		// Nobody waits for the result, don't even bother sending.
+		return nil, err
+	}
+
+	if err := t.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer t.unlock()
+
+	if t.conn == nil {
+		t.reconnects.Add(1)
+		if err := t.connect(); err != nil {
+			t.log.Warn("failed to reconnect", "error", err)
+			return nil, err
+		}
+		t.log.Info("reconnected")
+	}
+
+	if err := t.conn.SetWriteDeadline(
+		deadline(ctx, t.writeTimeout)); err != nil {
+		err = errors.Wrap(err, "failed to set connection deadline")
+		t.reset(err)
+		return nil, err
+	}
+
+	if done := ctx.Done(); done != nil {
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		defer func() {
+			// Make sure the deadline isn't touched once the connection is
+			// used by the next request.
+			close(stop)
+			<-stopped
+		}()
+
+		conn := t.conn
+		go func() {
+			defer close(stopped)
+			select {
+			case <-done:
+				// Interrupt blocked write or read, the connection is reset
+				// afterwards, so an error is meaningless here.
+				_ = conn.SetDeadline(time.Now())
+			case <-stop:
+			}
+		}()
+	}
+
+	t.w = msg.AppendTo(t.w[:0])
+	out := t.w
 	if n, err := t.conn.Write(out); err != nil {
-		return nil, errors.Wrapf(err,
+		err = errors.Wrapf(contextError(ctx, err),
 			"failed to sent message: %s", msg)
+		t.reset(err)
+		return nil, err
 	} else if n != len(out) {
-		return nil, errors.Wrapf(err,
-			"message was sent partially: %s", msg)
+		err = errors.Errorf("message was sent partially: %s", msg)
+		t.reset(err)
+		return nil, err
+	}
+
+	if !message.NeedResponse(msg) {
+		return nil, nil
+	}
+
+	if err := t.conn.SetReadDeadline(
+		deadline(ctx, t.readTimeout)); err != nil {
+		err = errors.Wrap(err, "failed to set connection deadline")
+		t.reset(err)
+		return nil, err
 	}
 
-	if message.NeedResponse(msg) {
+	var reply *message.Message
+	for reply == nil {
 		resp, err := t.r.ReadString(terminatorByte)
 		if err != nil {
-			return nil, errors.Wrapf(err,
+			err = errors.Wrapf(contextError(ctx, err),
 				"failed to receive response for: %s", msg)
+			t.reset(err)
+			return nil, err
+		}
+
+		if reply, err = message.ParsePartial(resp); err != nil {
+			err = errors.Wrapf(err, "failed to parse response for: %s", msg)
+			t.reset(err)
+			return nil, err
 		}
 
-		return message.ParsePartial(resp)
+		// Unsolicited messages are delivered to subscribers by a dedicated
+		// listener connection, here they are just skipped.
+		if reply.IsUnsolicited() {
+			reply = nil
+		}
+	}
+
+	if !reply.IsReplyTo(msg) {
+		err := &DesyncError{Request: msg, Reply: reply}
+		t.reset(err)
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// deadline returns the earliest of the deadline of a context and a timeout
+// from now. Zero time means no deadline.
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	out, _ := ctx.Deadline()
+	if timeout <= 0 {
+		return out
+	}
+
+	if limit := time.Now().Add(timeout); out.IsZero() || limit.Before(out) {
+		return limit
 	}
 
-	return nil, nil
+	return out
+}
+
+// contextError returns an error of a context, when it is done, since it is
+// the reason of I/O failure then. Otherwise I/O error is returned as is.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	// The connection deadline may pass a bit earlier than the context is
+	// marked as done.
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+
+	return err
+}
+
+// IsAlive returns true when the transceiver is started and not terminated
+// yet.
+func (t *Transceiver) IsAlive() bool { return t.alive.Load() }
+
+// Reconnects returns the number of times the connection was re-established
+// after a reset.
+func (t *Transceiver) Reconnects() int64 { return t.reconnects.Load() }
+
+// KeepAliveFailures returns the number of failed keep alive probes.
+func (t *Transceiver) KeepAliveFailures() int64 {
+	return t.keepAliveFailures.Load()
+}
+
+// keepAliveTimeout returns the limit of a keep alive probe: the read timeout
+// or the keep alive interval, when there is no read timeout.
+func (t *Transceiver) keepAliveTimeout() time.Duration {
+	if t.readTimeout > 0 {
+		return t.readTimeout
+	}
+	if t.KeepAliveDuration > 0 {
+		return t.KeepAliveDuration
+	}
+
+	return KeepAliveDuration
 }
 
 func (t *Transceiver) Go() <-chan error {
+	if t.KeepAliveDuration < 0 {
+		// The keep alive loop of chanfan can't be stopped, so it just
+		// ticks rarely.
+		t.KeepAliveDuration = math.MaxInt64
+	}
+
 	t.KeepAlive = func() error {
-		_, err := t.transceive(message.NewQueryTime())
-		return err
+		// The probe must not hold the connection forever, when the router
+		// doesn't answer, requests wait for it.
+		ctx, cancel := context.WithTimeout(context.Background(),
+			t.keepAliveTimeout())
+		defer cancel()
+
+		msg := t.probe()
+		start := time.Now()
+		reply, err := t.transceive(NewRequest(ctx, msg))
+		if err != nil {
+			t.keepAliveFailures.Add(1)
+			t.log.Warn("keep alive failed", "error", err)
+			return err
+		}
+
+		ping := Ping{At: time.Now(), RTT: time.Since(start)}
+		if msg.GetCommandID() == message.QueryTime {
+			if ping, err = newPing(reply, start, ping.At); err != nil {
+				t.keepAliveFailures.Add(1)
+				t.log.Warn("keep alive failed", "error", err)
+				return err
+			}
+		}
+
+		t.statusMu.Lock()
+		t.status.KeepAlive = ping
+		t.statusMu.Unlock()
+		return nil
 	}
 
+	t.alive.Store(true)
 	return t.Transceiver.Go(t.transceive)
 }
@@ -0,0 +1,107 @@
+package helvargo
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nuqz/chanfan"
+	"github.com/nuqz/helvar-go/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedDial returns a DialFunc, where every new connection replies to
+// incoming requests with the next reply from a script.
+func scriptedDial(replies ...string) (DialFunc, *int) {
+	script := make(chan string, len(replies))
+	for _, reply := range replies {
+		script <- reply
+	}
+	close(script)
+
+	nDials := 0
+	return func() (net.Conn, error) {
+		nDials++
+		client, srv := net.Pipe()
+		go func() {
+			defer srv.Close()
+			r := bufio.NewReader(srv)
+			for {
+				if _, err := r.ReadString(terminatorByte); err != nil {
+					return
+				}
+				reply, ok := <-script
+				if !ok {
+					return
+				}
+				if _, err := srv.Write([]byte(reply)); err != nil {
+					return
+				}
+			}
+		}()
+		return client, nil
+	}, &nDials
+}
+
+func transceiveOnce(
+	in chan<- *chanfan.IO[*Request, *message.Message],
+	msg *message.Message,
+) *chanfan.Result[*message.Message] {
+	ret := make(chan *chanfan.Result[*message.Message])
+	in <- chanfan.NewIO(NewRequest(context.Background(), msg), ret)
+	return <-ret
+}
+
+func TestTransceiverDesync(t *testing.T) {
+	dial, nDials := scriptedDial(
+		"?V:1,C:105,G:2=Group 2#",
+		"?V:1,C:105,G:1=Group 1#",
+	)
+
+	in := make(chan *chanfan.IO[*Request, *message.Message])
+	tr, err := NewTransceiver(dial, in)
+	require.NoError(t, err)
+	errs := tr.Go()
+
+	res := transceiveOnce(in, message.NewQueryGroupDescription(1))
+	var desync *DesyncError
+	require.ErrorAs(t, res.Error, &desync)
+	assert.Equal(t, uint16(2), desync.Reply.GetGroupID())
+
+	res = transceiveOnce(in, message.NewQueryGroupDescription(1))
+	require.NoError(t, res.Error)
+	assert.Equal(t, "Group 1", res.Value.Answer)
+	assert.Equal(t, 2, *nDials)
+
+	close(in)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestRequestAbandon(t *testing.T) {
+	queued := NewRequest(context.Background(), message.NewQueryGroups())
+	queued.abandon()
+	assert.False(t, queued.take())
+
+	taken := NewRequest(context.Background(), message.NewQueryGroups())
+	require.True(t, taken.take())
+
+	abandoned := make(chan struct{})
+	go func() {
+		taken.abandon()
+		close(abandoned)
+	}()
+
+	select {
+	case <-abandoned:
+		t.Fatal("abandon returned while the message is in use")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(taken.done)
+	<-abandoned
+}
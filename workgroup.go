@@ -0,0 +1,387 @@
+package helvargo
+
+import (
+	"context"
+	stderrors "errors"
+	"image/color"
+	"strings"
+	"sync"
+
+	"github.com/nuqz/helvar-go/members"
+	"github.com/nuqz/helvar-go/message"
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownRouter is returned by Workgroup for devices behind a router,
+// which is not a part of the workgroup.
+var ErrUnknownRouter = errors.New("device is behind an unknown router")
+
+// routerKeyOf returns the cluster.router part of a device address, e.g.
+// "1.251" of "1.251.2.15".
+func routerKeyOf(addr string) string {
+	parts := strings.SplitN(strings.TrimPrefix(addr, "@"), ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+
+	return parts[0] + "." + parts[1]
+}
+
+// Workgroup is a client of several routers of one workgroup. Device
+// commands and queries are routed by the cluster.router part of a device
+// address, while group commands and discovery go to the designated router.
+type Workgroup struct {
+	designated *Client
+	clients    []*Client
+
+	mu      sync.RWMutex
+	routers map[string]*Client
+}
+
+// NewWorkgroup returns a workgroup client made of clients of its routers,
+// each of them may be configured individually. The designated router is
+// used for group commands and discovery, it takes part in routing of device
+// commands as well.
+//
+// HelvarNET has no query of a router's own identity, so every router of a
+// workgroup of several routers must be given its ID with WithRouterID. A
+// partial ID is enough, Connect looks up the rest, see Client.Identify.
+func NewWorkgroup(designated *Client, routers ...*Client) (*Workgroup, error) {
+	w := &Workgroup{
+		designated: designated,
+		clients:    append([]*Client{designated}, routers...),
+	}
+
+	if len(w.clients) > 1 {
+		for _, c := range w.clients {
+			if !c.hasRouterID() {
+				return nil, errors.Errorf(
+					"router ID of %s is unknown, set it with WithRouterID",
+					c.address)
+			}
+		}
+	}
+
+	keys := make([]string, len(w.clients))
+	for i, c := range w.clients {
+		keys[i], _ = c.routerKey()
+	}
+	if err := w.index(keys); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// index routes devices to clients by given keys of their routers, empty
+// keys are skipped.
+func (w *Workgroup) index(keys []string) error {
+	routers := map[string]*Client{}
+	for i, c := range w.clients {
+		if keys[i] == "" {
+			continue
+		}
+		if other, ok := routers[keys[i]]; ok {
+			return errors.Errorf("%s and %s are the same router %s",
+				other.address, c.address, keys[i])
+		}
+
+		routers[keys[i]] = c
+	}
+
+	w.mu.Lock()
+	w.routers = routers
+	w.mu.Unlock()
+
+	return nil
+}
+
+// Designated returns the client of the designated router.
+func (w *Workgroup) Designated() *Client { return w.designated }
+
+// Router returns the client of the router a given device is behind.
+func (w *Workgroup) Router(addr string) (*Client, error) {
+	w.mu.RLock()
+	c, ok := w.routers[routerKeyOf(addr)]
+	w.mu.RUnlock()
+
+	if ok {
+		return c, nil
+	}
+
+	return nil, errors.Wrapf(ErrUnknownRouter, "%s", addr)
+}
+
+// Connect connects clients of all routers and identifies them, see
+// Client.Connect and Client.Identify. Clients already connected are
+// disconnected, if any of them fails.
+func (w *Workgroup) Connect() ([]<-chan error, error) {
+	out := []<-chan error{}
+	keys := make([]string, len(w.clients))
+	for i, c := range w.clients {
+		errs, err := c.Connect()
+		if err == nil {
+			var id RouterID
+			id, err = c.Identify(context.Background())
+			keys[i] = id.String()
+			out = append(out, errs...)
+		}
+
+		if err != nil {
+			for _, connected := range w.clients[:i+1] {
+				connected.Disconnect()
+			}
+			return nil, err
+		}
+	}
+
+	if err := w.index(keys); err != nil {
+		w.Disconnect()
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Close closes clients of all routers concurrently, see Client.Close.
+func (w *Workgroup) Close(ctx context.Context) error {
+	errs := make([]error, len(w.clients))
+
+	var wg sync.WaitGroup
+	for i, c := range w.clients {
+		wg.Add(1)
+		go func(i int, c *Client) {
+			defer wg.Done()
+			errs[i] = c.Close(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	return stderrors.Join(errs...)
+}
+
+// Disconnect disconnects clients of all routers.
+func (w *Workgroup) Disconnect() {
+	for _, c := range w.clients {
+		c.Disconnect()
+	}
+}
+
+// route returns the client a given message should be sent with.
+func (w *Workgroup) route(msg *message.Message) (*Client, error) {
+	if spec, ok := message.LookupCommand(msg.GetCommandID()); ok &&
+		spec.Target == message.TargetDevice {
+		return w.Router(msg.GetAddress())
+	}
+
+	return w.designated, nil
+}
+
+// Transceive sends a message to the router of a device it is addressed to
+// or to the designated router otherwise.
+func (w *Workgroup) Transceive(msg *message.Message) (*message.Message, error) {
+	return w.TransceiveContext(context.Background(), msg)
+}
+
+// TransceiveContext is Transceive limited by a given context, see
+// Client.TransceiveContext.
+func (w *Workgroup) TransceiveContext(
+	ctx context.Context,
+	msg *message.Message,
+) (*message.Message, error) {
+	c, err := w.route(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.TransceiveContext(ctx, msg)
+}
+
+// Topology is a network of a workgroup as a whole.
+type Topology struct {
+	Clusters []members.Cluster
+	Routers  []members.Router
+	Groups   []members.Group
+}
+
+// Discover returns the topology of the workgroup. Clusters, routers and
+// groups are queried from the designated router, while names and states of
+// devices are queried from routers they are behind. Devices behind routers,
+// which are not a part of the workgroup, have addresses only.
+func (w *Workgroup) Discover() (Topology, error) {
+	var (
+		out Topology
+		err error
+	)
+
+	if out.Clusters, err = w.designated.GetClusters(); err != nil {
+		return out, err
+	}
+	for _, cluster := range out.Clusters {
+		routers, err := w.designated.GetClusterRouters(cluster.ID)
+		if err != nil {
+			return out, err
+		}
+		out.Routers = append(out.Routers, routers...)
+	}
+	if out.Groups, err = w.designated.GetGroups(); err != nil {
+		return out, err
+	}
+
+	for i := range out.Groups {
+		g := &out.Groups[i]
+		if g.Name, err = w.designated.GetGroupName(*g); err != nil {
+			return out, err
+		}
+		if g.Devices, err = w.designated.GetDevices(*g); err != nil {
+			return out, err
+		}
+
+		for j := range g.Devices {
+			d := &g.Devices[j]
+			c, err := w.Router(d.Address)
+			if err != nil {
+				continue
+			}
+
+			if d.Name, err = c.GetDeviceName(*d); err != nil {
+				return out, err
+			}
+			if d.State, err = c.GetDeviceState(*d); err != nil {
+				return out, err
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (w *Workgroup) GetDeviceName(d members.Device) (string, error) {
+	c, err := w.Router(d.Address)
+	if err != nil {
+		return "", err
+	}
+
+	return c.GetDeviceName(d)
+}
+
+func (w *Workgroup) GetDeviceState(
+	d members.Device,
+) (members.DeviceState, error) {
+	c, err := w.Router(d.Address)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.GetDeviceState(d)
+}
+
+func (w *Workgroup) RecallSceneGroup(
+	gid uint16,
+	block, scene uint8,
+	params ...message.Parameter,
+) error {
+	return w.designated.RecallSceneGroup(gid, block, scene, params...)
+}
+
+func (w *Workgroup) RecallSceneDevice(
+	addr string,
+	block, scene uint8,
+	params ...message.Parameter,
+) error {
+	c, err := w.Router(addr)
+	if err != nil {
+		return err
+	}
+
+	return c.RecallSceneDevice(addr, block, scene, params...)
+}
+
+func (w *Workgroup) DirectLevelGroup(
+	gid uint16,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	return w.designated.DirectLevelGroup(gid, level, params...)
+}
+
+func (w *Workgroup) DirectLevelDevice(
+	addr string,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	c, err := w.Router(addr)
+	if err != nil {
+		return err
+	}
+
+	return c.DirectLevelDevice(addr, level, params...)
+}
+
+func (w *Workgroup) ColorTemperatureGroup(
+	gid, tempK uint16,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	return w.designated.ColorTemperatureGroup(gid, tempK, level, params...)
+}
+
+func (w *Workgroup) ColorTemperatureDevice(
+	addr string,
+	tempK uint16,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	c, err := w.Router(addr)
+	if err != nil {
+		return err
+	}
+
+	return c.ColorTemperatureDevice(addr, tempK, level, params...)
+}
+
+func (w *Workgroup) ColorGroup(
+	gid uint16,
+	color color.Color,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	return w.designated.ColorGroup(gid, color, level, params...)
+}
+
+func (w *Workgroup) ColorDevice(
+	addr string,
+	color color.Color,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	c, err := w.Router(addr)
+	if err != nil {
+		return err
+	}
+
+	return c.ColorDevice(addr, color, level, params...)
+}
+
+func (w *Workgroup) RGBGroup(
+	gid uint16,
+	r, g, b byte,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	return w.designated.RGBGroup(gid, r, g, b, level, params...)
+}
+
+func (w *Workgroup) RGBDevice(
+	addr string,
+	r, g, b byte,
+	level uint8,
+	params ...message.Parameter,
+) error {
+	c, err := w.Router(addr)
+	if err != nil {
+		return err
+	}
+
+	return c.RGBDevice(addr, r, g, b, level, params...)
+}
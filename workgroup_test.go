@@ -0,0 +1,110 @@
+package helvargo
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/nuqz/helvar-go/members"
+	"github.com/nuqz/helvar-go/message"
+	ht "github.com/nuqz/helvar-go/testing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterKeyOf(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"1.251.2.15":  "1.251",
+		"@1.251.2.15": "1.251",
+		"1.252.1":     "1.252",
+		"1":           "",
+		"":            "",
+	} {
+		assert.Equal(t, expected, routerKeyOf(addr), addr)
+	}
+}
+
+func TestWorkgroup(t *testing.T) {
+	designatedNet := ht.MustNetFromYAMLFile(
+		path.Join("testing", "test_net.yml"))
+	designated := ht.NewRouter("", designatedNet)
+	other := ht.NewRouter("", ht.Network{
+		Clusters: designatedNet.Clusters,
+		Routers:  designatedNet.Routers,
+		Groups: []members.Group{{
+			ID: 12,
+			Devices: []members.Device{{
+				Address: "2.252.1",
+				Name:    "Lamp of router 252",
+				State:   members.NSLampFailure,
+			}},
+		}},
+	})
+
+	_, err := NewWorkgroup(
+		NewClient("router1.example.com", WithConnFactory(designated.Dial),
+			WithRouterID(RouterID{Cluster: 1, Router: 251})),
+		NewClient("router2.example.com", WithConnFactory(other.Dial),
+			WithRouterID(RouterID{Cluster: 1, Router: 251})))
+	assert.Error(t, err)
+
+	// Routers of the workgroup can't be told apart without their IDs.
+	_, err = NewWorkgroup(
+		NewClient("router1.example.com", WithConnFactory(designated.Dial),
+			WithRouterID(RouterID{Cluster: 1, Router: 251})),
+		NewClient("router2.example.com", WithConnFactory(other.Dial)))
+	assert.ErrorContains(t, err, "WithRouterID")
+
+	w, err := NewWorkgroup(
+		NewClient("router1.example.com", WithConnFactory(designated.Dial),
+			WithRouterID(RouterID{Cluster: 1, Router: 251})),
+		// The cluster of the router is looked up by Connect.
+		NewClient("router2.example.com", WithConnFactory(other.Dial),
+			WithRouterID(RouterID{Router: 252})))
+	require.NoError(t, err)
+
+	_, err = w.Connect()
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, w.Close(context.Background())) }()
+
+	name, err := w.GetDeviceName(members.Device{Address: "2.252.1"})
+	require.NoError(t, err)
+	assert.Equal(t, "Lamp of router 252", name)
+
+	name, err = w.GetDeviceName(members.Device{Address: "1.251.1"})
+	require.NoError(t, err)
+	assert.Equal(t, "Lamp 1 in Group 11", name)
+
+	_, err = w.GetDeviceName(members.Device{Address: "3.253.1"})
+	assert.True(t, errors.Is(err, ErrUnknownRouter), err)
+	assert.True(t, errors.Is(w.DirectLevelDevice("3.253.1", 50),
+		ErrUnknownRouter))
+	assert.NoError(t, w.DirectLevelDevice("2.252.1", 50))
+	assert.NoError(t, w.RecallSceneGroup(11, 1, 1))
+
+	// QueryRouters is addressed to a cluster, not to a device.
+	reply, err := w.Transceive(message.NewQueryRouters("1"))
+	require.NoError(t, err)
+	assert.Equal(t, "251", reply.Answer)
+
+	topology, err := w.Discover()
+	require.NoError(t, err)
+	assert.Len(t, topology.Clusters, len(designatedNet.Clusters))
+	assert.Equal(t, []members.Router{
+		{ID: 251, Cluster: 1}, {ID: 252, Cluster: 2}, {ID: 253, Cluster: 3},
+	}, topology.Routers)
+	require.Len(t, topology.Groups, len(designatedNet.Groups))
+	assert.Equal(t, members.Group{
+		ID:   11,
+		Name: "Group 11",
+		Devices: []members.Device{
+			{Address: "1.251.1", Name: "Lamp 1 in Group 11"},
+		},
+	}, topology.Groups[0])
+	assert.Equal(t, []members.Device{{
+		Address: "2.252.1",
+		Name:    "Lamp of router 252",
+		State:   members.NSLampFailure,
+	}}, topology.Groups[1].Devices)
+}